@@ -20,6 +20,7 @@ type (
 		GetByEmail(ctx context.Context, email string) (domain.Account, error)
 		GetByLogin(ctx context.Context, username string) (domain.Account, error)
 		GetById(ctx context.Context, userId string) (domain.Account, error)
+		GetCredentials(ctx context.Context, username string) (domain.Credentials, error)
 		UpdateVerificationStatus(ctx context.Context, request domain.UpdateVerificationRequestData) (bool, error)
 	}
 
@@ -104,3 +105,16 @@ func (a *adapter) GetByLogin(ctx context.Context, username string) (domain.Accou
 
 	return converters.ProtoUser2DomainAccount(resp), nil
 }
+
+func (a *adapter) GetCredentials(ctx context.Context, username string) (domain.Credentials, error) {
+	resp, err := a.client.GetUserCredentials(ctx, &warehousepb.GetUserCredentialsRequest{Username: username})
+
+	if err != nil {
+		return domain.Credentials{}, err
+	}
+
+	return domain.Credentials{
+		Account: converters.ProtoUser2DomainAccount(resp.User),
+		Hash:    resp.Hash,
+	}, nil
+}
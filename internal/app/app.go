@@ -30,6 +30,9 @@ func (app *application) Run() {
 	grpcServer := app.deps.GrpcServer()
 	grpcServer.Start()
 
+	scheduler := app.deps.Scheduler()
+	scheduler.Start()
+
 	app.deps.WaitForInterrupr() // программа будет "стоять" тут пока не придет системный сигнал
 	app.deps.Close()
 }
@@ -4,11 +4,26 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/spf13/viper"
 )
 
+const defaultJwtKeyId = "default"
+
+// хранилище репозиториев. memory - для разработки и тестов, данные живут до рестарта
+const (
+	StoragePostgres = "postgres"
+	StorageMemory   = "memory"
+)
+
+const (
+	SessionPolicyReject   = "reject"
+	SessionPolicyEvictLru = "evict_lru"
+)
+
 type (
 	GrpcServer struct {
 		Address string
@@ -19,17 +34,57 @@ type (
 		User GrpcServer
 	}
 
+	// JwtKey ключ подписи. Key - HMAC секрет или приватный ключ в PEM для асимметричных алгоритмов
+	JwtKey struct {
+		Id            string
+		SigningMethod string
+		Key           string
+		RetireAt      time.Time
+	}
+
+	// SessionLimit ограничение одновременных сессий роли. Max 0 - без ограничения
+	SessionLimit struct {
+		Max    int
+		Policy string
+	}
+
 	Auth struct {
-		Key                 string
+		Keys      []JwtKey
+		ActiveKey string
+		Issuer    string
+		Audience  []string
+		// ServiceAudience аудитория самого auth сервиса, ее проверяют его маршруты. Пусто - первая из Audience
+		ServiceAudience     string
 		AccessTokenTimeout  time.Duration
 		RefreshTokenTimeout time.Duration
 		AuthTimeout         time.Duration
+		SessionLimits       map[int64]SessionLimit
+		// SessionIdleTimeout сессия без обмена refresh токена дольше этого времени истекает. 0 - без ограничения
+		SessionIdleTimeout time.Duration
+		// SessionMaxAge абсолютное время жизни сессии с момента логина, ротация его не продлевает. 0 - без ограничения
+		SessionMaxAge time.Duration
+		// ImpersonationTimeout время жизни токена, выпущенного админом от имени пользователя. 0 - как у access токена
+		ImpersonationTimeout time.Duration
+		// Scopes права, которые получает роль. Ключ - номер роли
+		Scopes map[int64][]string
+		// ImpersonationScopes права токена имперсонации, если админ не запросил свои. Берутся только скоупы роли пользователя
+		ImpersonationScopes []string
+		// DPoPProofLifetime насколько iat DPoP proof может отличаться от текущего времени. 0 - минута
+		DPoPProofLifetime time.Duration
+	}
+
+	// ServiceAuth учетные данные внутренних сервисов: общий секрет и пары client_id/secret для Basic авторизации
+	ServiceAuth struct {
+		Secret  string
+		Clients map[string]string
 	}
 
 	Timeouts struct {
 		AuthTimeout    time.Duration
 		RequestTimeout time.Duration
 		AccCookie      time.Duration
+		// StepUp насколько давним может быть интерактивный вход для чувствительных действий
+		StepUp time.Duration
 	}
 
 	Postgres struct {
@@ -47,6 +102,11 @@ type (
 		Mode           string
 		Port           int
 		AllowedOrigins []string
+		// PublicUrl внешний адрес сервиса за прокси, с ним сверяется htu в DPoP proof. Пусто - адрес берется из запроса
+		PublicUrl string
+		// TrustedProxies сколько прокси перед сервисом дописывают адрес в X-Forwarded-For. Клиент - адрес,
+		// дописанный самым дальним из них, остальное подставляет сам клиент. 0 - заголовок не читается, берется RemoteAddr
+		TrustedProxies int
 	}
 
 	Mail struct {
@@ -60,37 +120,130 @@ type (
 		Locale int64
 	}
 
+	// Cleanup фоновая чистка протухших токенов
+	Cleanup struct {
+		Interval  time.Duration
+		BatchSize int
+	}
+
+	// Lockout блокировка входа после неудачных попыток. Threshold 0 - без блокировки по логину, IpThreshold 0 - по ip
+	Lockout struct {
+		Threshold   int
+		IpThreshold int
+		// BaseDelay первая блокировка, каждая следующая неудачная попытка удваивает ее, но не больше MaxDelay
+		BaseDelay time.Duration
+		MaxDelay  time.Duration
+		// Window через сколько после последней неудачной попытки счетчик сбрасывается
+		Window time.Duration
+	}
+
+	// PasswordPolicy требования к новым паролям. Нулевое значение отключает проверку
+	PasswordPolicy struct {
+		MinLength int
+		// MinClasses сколько разных классов символов нужно: строчные, заглавные, цифры, остальные
+		MinClasses int
+		// MinEntropy минимальная офлайн оценка стойкости в битах
+		MinEntropy float64
+		// BreachedFile файл с sha1 утекших паролей, по одному hex хешу в строке (формат HIBP, счетчик после ':' игнорируется)
+		BreachedFile string
+	}
+
 	Config struct {
-		Server   Server
-		Rabbit   Rabbit
-		Auth     Auth
-		Mail     Mail
-		Timeouts Timeouts
-		Postgres Postgres
-		Grpc     Grpc
-		Time     Time
+		Storage     string
+		Server      Server
+		Rabbit      Rabbit
+		Auth        Auth
+		ServiceAuth ServiceAuth
+		Mail        Mail
+		Timeouts    Timeouts
+		Postgres    Postgres
+		Grpc        Grpc
+		Time        Time
+		Cleanup     Cleanup
+		Lockout     Lockout
+		Password    PasswordPolicy
 	}
 )
 
-func loadJwtKey(v *viper.Viper, isProd bool) (string, error) {
+func readSecret(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	var jwtCreds struct {
+		Key string `json:"key"`
+	}
+	if err := json.Unmarshal(data, &jwtCreds); err != nil {
+		return "", err
+	}
+
+	return jwtCreds.Key, nil
+}
+
+func loadSecret(v *viper.Viper, isProd bool, key string) (string, error) {
 	if isProd {
-		path := v.GetString("apis.jwt")
-		data, err := os.ReadFile(path)
+		return readSecret(v.GetString(key))
+	} else {
+		value := v.GetString(key)
+		return value, nil
+	}
+}
+
+// loadJwtKeys собирает связку ключей подписи. Если jwt.keys не задан, используется
+// единственный ключ из apis.jwt (apis.jwt_private_key для асимметричной подписи)
+func loadJwtKeys(v *viper.Viper, isProd bool) ([]JwtKey, string, error) {
+	var rawKeys []struct {
+		Id            string `mapstructure:"id"`
+		SigningMethod string `mapstructure:"signing_method"`
+		Key           string `mapstructure:"key"`
+		RetireAt      string `mapstructure:"retire_at"`
+	}
+	if err := v.UnmarshalKey("jwt.keys", &rawKeys); err != nil {
+		return nil, "", err
+	}
+
+	if len(rawKeys) == 0 {
+		signingMethod := v.GetString("jwt.signing_method")
+		source := "apis.jwt"
+		if signingMethod != "" && !strings.HasPrefix(signingMethod, "HS") {
+			source = "apis.jwt_private_key"
+		}
+
+		key, err := loadSecret(v, isProd, source)
 		if err != nil {
-			return "", err
+			return nil, "", err
+		}
+
+		return []JwtKey{{Id: defaultJwtKeyId, SigningMethod: signingMethod, Key: key}}, defaultJwtKeyId, nil
+	}
+
+	keys := make([]JwtKey, 0, len(rawKeys))
+	for _, raw := range rawKeys {
+		key := JwtKey{
+			Id:            raw.Id,
+			SigningMethod: raw.SigningMethod,
+			Key:           raw.Key,
 		}
-		var jwtCreds struct {
-			Key string `json:"key"`
+
+		// в проде в key лежит путь до файла с ключом, как и в apis.jwt
+		if isProd {
+			var err error
+			if key.Key, err = readSecret(raw.Key); err != nil {
+				return nil, "", fmt.Errorf("load jwt key %s: %w", raw.Id, err)
+			}
 		}
-		if err := json.Unmarshal(data, &jwtCreds); err != nil {
-			return "", err
+
+		if raw.RetireAt != "" {
+			var err error
+			if key.RetireAt, err = time.Parse(time.RFC3339, raw.RetireAt); err != nil {
+				return nil, "", fmt.Errorf("parse retire_at of jwt key %s: %w", raw.Id, err)
+			}
 		}
 
-		return jwtCreds.Key, nil
-	} else {
-		value := v.GetString("apis.jwt")
-		return value, nil
+		keys = append(keys, key)
 	}
+
+	return keys, v.GetString("jwt.active_key"), nil
 }
 
 func loadPgSource(v *viper.Viper, isProd bool) (string, error) {
@@ -114,6 +267,81 @@ func loadPgSource(v *viper.Viper, isProd bool) (string, error) {
 	}
 }
 
+// loadServiceAuth в проде файл apis.service_auth необязателен: без него сервисы ходят только по client credentials
+// или с секретами из service_auth. Указанный, но нечитаемый файл - ошибка
+func loadServiceAuth(v *viper.Viper, isProd bool) (ServiceAuth, error) {
+	if path := v.GetString("apis.service_auth"); isProd && path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ServiceAuth{}, err
+		}
+		var serviceAuth struct {
+			Secret  string            `json:"secret"`
+			Clients map[string]string `json:"clients"`
+		}
+		if err := json.Unmarshal(data, &serviceAuth); err != nil {
+			return ServiceAuth{}, err
+		}
+
+		return ServiceAuth{Secret: serviceAuth.Secret, Clients: serviceAuth.Clients}, nil
+	} else {
+		return ServiceAuth{
+			Secret:  v.GetString("service_auth.secret"),
+			Clients: v.GetStringMapString("service_auth.clients"),
+		}, nil
+	}
+}
+
+// loadSessionLimits ключи sessions.limits - номера ролей
+func loadSessionLimits(v *viper.Viper) (map[int64]SessionLimit, error) {
+	limits := make(map[int64]SessionLimit)
+	for key := range v.GetStringMap("sessions.limits") {
+		role, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("sessions.limits: invalid role %q", key)
+		}
+
+		limit := SessionLimit{
+			Max:    v.GetInt(fmt.Sprintf("sessions.limits.%s.max", key)),
+			Policy: v.GetString(fmt.Sprintf("sessions.limits.%s.policy", key)),
+		}
+		if limit.Policy == "" {
+			limit.Policy = SessionPolicyReject
+		}
+		if limit.Policy != SessionPolicyReject && limit.Policy != SessionPolicyEvictLru {
+			return nil, fmt.Errorf("sessions.limits.%s: unknown policy %q", key, limit.Policy)
+		}
+		if limit.Max < 0 {
+			return nil, fmt.Errorf("sessions.limits.%s: max must not be negative", key)
+		}
+
+		limits[role] = limit
+	}
+
+	return limits, nil
+}
+
+// loadScopes ключи jwt.scopes - номера ролей
+func loadScopes(v *viper.Viper) (map[int64][]string, error) {
+	scopes := make(map[int64][]string)
+	for key := range v.GetStringMap("jwt.scopes") {
+		role, err := strconv.ParseInt(key, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("jwt.scopes: invalid role %q", key)
+		}
+
+		roleScopes := v.GetStringSlice(fmt.Sprintf("jwt.scopes.%s", key))
+		for _, scope := range roleScopes {
+			if scope == "" || strings.ContainsAny(scope, " \t\n") {
+				return nil, fmt.Errorf("jwt.scopes.%s: invalid scope %q", key, scope)
+			}
+		}
+		scopes[role] = roleScopes
+	}
+
+	return scopes, nil
+}
+
 func generateRabbitUrl(v *viper.Viper) string {
 	return fmt.Sprintf(
 		"amqp://%s:%s@%s:%d/",
@@ -139,17 +367,49 @@ func NewConfig(cfgPath string) (*Config, error) {
 	}
 	mode := v.GetString("mode")
 
-	jwtKey, err := loadJwtKey(v, mode == "prod")
+	jwtKeys, activeJwtKey, err := loadJwtKeys(v, mode == "prod")
+	if err != nil {
+		return nil, err
+	}
+
+	audience := v.GetStringSlice("jwt.audience")
+	serviceAudience := v.GetString("jwt.service_audience")
+	if serviceAudience == "" && len(audience) > 0 {
+		serviceAudience = audience[0]
+	}
+
+	storage := v.GetString("storage")
+	if storage == "" {
+		storage = StoragePostgres
+	}
+	if storage != StoragePostgres && storage != StorageMemory {
+		return nil, fmt.Errorf("unknown storage %q", storage)
+	}
+
+	var pgSource string
+	if storage == StoragePostgres {
+		if pgSource, err = loadPgSource(v, mode == "prod"); err != nil {
+			return nil, err
+		}
+	}
+
+	serviceAuth, err := loadServiceAuth(v, mode == "prod")
+	if err != nil {
+		return nil, err
+	}
+
+	sessionLimits, err := loadSessionLimits(v)
 	if err != nil {
 		return nil, err
 	}
 
-	pgSource, err := loadPgSource(v, mode == "prod")
+	scopes, err := loadScopes(v)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Config{
+		Storage: storage,
 		Mail: Mail{
 			Email:    v.GetString("mail.email"),
 			Password: v.GetString("mail.password"),
@@ -157,15 +417,28 @@ func NewConfig(cfgPath string) (*Config, error) {
 			Port:     v.GetInt("mail.port"),
 		},
 		Auth: Auth{
-			Key:                 jwtKey,
-			AccessTokenTimeout:  Timeout(v, "access_token"),  // таймаут цифрами для ttl токена
-			RefreshTokenTimeout: Timeout(v, "refresh_token"), // таймаут цифрами для ttl токена
-			AuthTimeout:         Timeout(v, "request"),
+			Keys:                 jwtKeys,
+			ActiveKey:            activeJwtKey,
+			Issuer:               v.GetString("jwt.issuer"),
+			Audience:             audience,
+			ServiceAudience:      serviceAudience,
+			AccessTokenTimeout:   Timeout(v, "access_token"),  // таймаут цифрами для ttl токена
+			RefreshTokenTimeout:  Timeout(v, "refresh_token"), // таймаут цифрами для ttl токена
+			AuthTimeout:          Timeout(v, "request"),
+			SessionLimits:        sessionLimits,
+			SessionIdleTimeout:   Timeout(v, "session_idle"),
+			SessionMaxAge:        Timeout(v, "session_max_age"),
+			ImpersonationTimeout: Timeout(v, "impersonation"),
+			Scopes:               scopes,
+			ImpersonationScopes:  v.GetStringSlice("jwt.impersonation_scopes"),
+			DPoPProofLifetime:    Timeout(v, "dpop_proof"),
 		},
+		ServiceAuth: serviceAuth,
 		Timeouts: Timeouts{
 			RequestTimeout: v.GetDuration("request_timeout.request"), // общие таймауты (можно переносить между сервисами)
 			AuthTimeout:    v.GetDuration("request_timeout.auth"),
 			AccCookie:      v.GetDuration("acc_cookie"),
+			StepUp:         Timeout(v, "step_up"),
 		},
 		Grpc: Grpc{
 			Auth: GrpcServer{
@@ -183,6 +456,8 @@ func NewConfig(cfgPath string) (*Config, error) {
 			Mode:           mode,
 			Port:           v.GetInt("server.port"),
 			AllowedOrigins: v.GetStringSlice("server.allowed_origins"),
+			PublicUrl:      v.GetString("server.public_url"),
+			TrustedProxies: v.GetInt("server.trusted_proxies"),
 		},
 
 		Rabbit: Rabbit{
@@ -194,6 +469,26 @@ func NewConfig(cfgPath string) (*Config, error) {
 		Time: Time{
 			Locale: v.GetInt64("locale"),
 		},
+
+		Cleanup: Cleanup{
+			Interval:  v.GetDuration("cleanup.interval"),
+			BatchSize: v.GetInt("cleanup.batch_size"),
+		},
+
+		Lockout: Lockout{
+			Threshold:   v.GetInt("lockout.threshold"),
+			IpThreshold: v.GetInt("lockout.ip_threshold"),
+			BaseDelay:   v.GetDuration("lockout.base_delay"),
+			MaxDelay:    v.GetDuration("lockout.max_delay"),
+			Window:      v.GetDuration("lockout.window"),
+		},
+
+		Password: PasswordPolicy{
+			MinLength:    v.GetInt("password_policy.min_length"),
+			MinClasses:   v.GetInt("password_policy.min_classes"),
+			MinEntropy:   v.GetFloat64("password_policy.min_entropy"),
+			BreachedFile: v.GetString("password_policy.breached_file"),
+		},
 	}, nil
 
 }
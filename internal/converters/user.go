@@ -32,9 +32,12 @@ func DomainUpdateVerification2ProtoUpdateVerification(request domain.UpdateVerif
 
 func ProtoUser2DomainAccount(response *warehousepb.User) domain.Account {
 	return domain.Account{
-		Id:       response.UserId,
-		Role:     domain.Role(response.Role),
-		Verified: response.Verified,
+		Id:        response.UserId,
+		Role:      domain.Role(response.Role),
+		Username:  response.Username,
+		Firstname: response.Firstname,
+		Email:     response.Email,
+		Verified:  response.Verified,
 	}
 }
 
@@ -48,3 +51,10 @@ func DomainUser2ProtoAccount(acc domain.Account) *warehousepb.User {
 		Email:     acc.Email,
 	}
 }
+
+func DomainPrincipalType2Proto(principal domain.PrincipalType) warehousepb.PrincipalType {
+	if principal == domain.PrincipalService {
+		return warehousepb.PrincipalType_PRINCIPAL_SERVICE
+	}
+	return warehousepb.PrincipalType_PRINCIPAL_USER
+}
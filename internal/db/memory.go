@@ -0,0 +1,191 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+var (
+	ErrMemoryTxDone     = errors.New("transaction has already been committed or rolled back")
+	ErrMemoryTxReadOnly = errors.New("cannot write in a read-only transaction")
+)
+
+type (
+	// MemoryClient хранилище в памяти для storage=memory. Пишущие транзакции выполняются по одной,
+	// читающие параллельно друг с другом, поэтому незакоммиченные изменения никому не видны
+	MemoryClient struct {
+		lock sync.RWMutex
+
+		tablesLock sync.Mutex
+		tables     map[string]interface{}
+	}
+
+	// MemoryTx транзакция хранилища в памяти. Перед первой записью в таблицу запоминается ее копия,
+	// откат возвращает копии на место
+	MemoryTx struct {
+		client   *MemoryClient
+		readOnly bool
+		done     bool
+		restore  map[string]func()
+	}
+
+	// MemoryTable таблица строк одного типа. Читать и писать можно только внутри транзакции
+	MemoryTable[T any] struct {
+		name string
+		rows []T
+	}
+)
+
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{
+		tables: make(map[string]interface{}),
+	}
+}
+
+// MemoryTableOf таблица с именем name, репозитории с одним именем работают с одной таблицей
+func MemoryTableOf[T any](client *MemoryClient, name string) *MemoryTable[T] {
+	client.tablesLock.Lock()
+	defer client.tablesLock.Unlock()
+
+	if table, ok := client.tables[name]; ok {
+		return table.(*MemoryTable[T])
+	}
+
+	table := &MemoryTable[T]{name: name}
+	client.tables[name] = table
+	return table
+}
+
+// Begin ждет, пока освободится хранилище, но не дольше, чем живет ctx
+func (c *MemoryClient) Begin(ctx context.Context, readOnly bool) (*MemoryTx, error) {
+	locked := make(chan struct{})
+	go func() {
+		if readOnly {
+			c.lock.RLock()
+		} else {
+			c.lock.Lock()
+		}
+		close(locked)
+	}()
+
+	select {
+	case <-locked:
+		return &MemoryTx{
+			client:   c,
+			readOnly: readOnly,
+			restore:  make(map[string]func()),
+		}, nil
+	case <-ctx.Done():
+		// блокировку все равно получим, ее нужно сразу отпустить
+		go func() {
+			<-locked
+			c.unlock(readOnly)
+		}()
+		return nil, ctx.Err()
+	}
+}
+
+func (c *MemoryClient) unlock(readOnly bool) {
+	if readOnly {
+		c.lock.RUnlock()
+	} else {
+		c.lock.Unlock()
+	}
+}
+
+func (t *MemoryTx) Commit() error {
+	if t.done {
+		return ErrMemoryTxDone
+	}
+	t.done = true
+	t.client.unlock(t.readOnly)
+	return nil
+}
+
+// Rollback после Commit ничего не делает, как и у sql.Tx
+func (t *MemoryTx) Rollback() {
+	if t.done {
+		return
+	}
+	for _, restore := range t.restore {
+		restore()
+	}
+	t.done = true
+	t.client.unlock(t.readOnly)
+}
+
+func (t *MemoryTable[T]) beforeWrite(tx *MemoryTx) error {
+	if tx.done {
+		return ErrMemoryTxDone
+	}
+	if tx.readOnly {
+		return ErrMemoryTxReadOnly
+	}
+	if _, ok := tx.restore[t.name]; !ok {
+		saved := append([]T(nil), t.rows...)
+		tx.restore[t.name] = func() {
+			t.rows = saved
+		}
+	}
+	return nil
+}
+
+// Select копии строк, для которых match вернул true, в порядке вставки
+func (t *MemoryTable[T]) Select(tx *MemoryTx, match func(T) bool) ([]T, error) {
+	if tx.done {
+		return nil, ErrMemoryTxDone
+	}
+
+	res := []T{}
+	for _, row := range t.rows {
+		if match(row) {
+			res = append(res, row)
+		}
+	}
+	return res, nil
+}
+
+func (t *MemoryTable[T]) Insert(tx *MemoryTx, row T) error {
+	if err := t.beforeWrite(tx); err != nil {
+		return err
+	}
+
+	t.rows = append(t.rows, row)
+	return nil
+}
+
+// Update применяет update ко всем подходящим строкам и возвращает их число
+func (t *MemoryTable[T]) Update(tx *MemoryTx, match func(T) bool, update func(*T)) (int64, error) {
+	if err := t.beforeWrite(tx); err != nil {
+		return 0, err
+	}
+
+	var updated int64
+	for i := range t.rows {
+		if match(t.rows[i]) {
+			update(&t.rows[i])
+			updated++
+		}
+	}
+	return updated, nil
+}
+
+// Delete удаляет не больше limit подходящих строк, limit 0 - без ограничения
+func (t *MemoryTable[T]) Delete(tx *MemoryTx, match func(T) bool, limit int) (int64, error) {
+	if err := t.beforeWrite(tx); err != nil {
+		return 0, err
+	}
+
+	var deleted int64
+	rows := make([]T, 0, len(t.rows))
+	for _, row := range t.rows {
+		if (limit == 0 || deleted < int64(limit)) && match(row) {
+			deleted++
+			continue
+		}
+		rows = append(rows, row)
+	}
+	t.rows = rows
+	return deleted, nil
+}
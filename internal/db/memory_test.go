@@ -0,0 +1,146 @@
+package db
+
+import (
+	"context"
+	"testing"
+)
+
+type testRow struct {
+	id    int
+	value string
+}
+
+func selectAll(t *testing.T, client *MemoryClient, table *MemoryTable[testRow]) []testRow {
+	t.Helper()
+
+	tx, err := client.Begin(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	rows, err := table.Select(tx, func(testRow) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	return rows
+}
+
+func seed(t *testing.T, client *MemoryClient, table *MemoryTable[testRow], rows ...testRow) {
+	t.Helper()
+
+	tx, err := client.Begin(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range rows {
+		if err = table.Insert(tx, row); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMemoryTxRollbackRestoresTables(t *testing.T) {
+	client := NewMemoryClient()
+	table := MemoryTableOf[testRow](client, "rows")
+	seed(t, client, table, testRow{1, "a"}, testRow{2, "b"})
+
+	tx, err := client.Begin(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = table.Insert(tx, testRow{3, "c"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.Update(tx, func(r testRow) bool { return r.id == 1 }, func(r *testRow) { r.value = "changed" }); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = table.Delete(tx, func(r testRow) bool { return r.id == 2 }, 0); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+
+	rows := selectAll(t, client, table)
+	if len(rows) != 2 || rows[0] != (testRow{1, "a"}) || rows[1] != (testRow{2, "b"}) {
+		t.Fatalf("rows after rollback = %+v, want the seeded rows", rows)
+	}
+}
+
+func TestMemoryTxRollbackAfterCommitKeepsChanges(t *testing.T) {
+	client := NewMemoryClient()
+	table := MemoryTableOf[testRow](client, "rows")
+
+	tx, err := client.Begin(context.Background(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = table.Insert(tx, testRow{1, "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	tx.Rollback()
+
+	if rows := selectAll(t, client, table); len(rows) != 1 {
+		t.Fatalf("rows = %+v, want the committed row", rows)
+	}
+	if err = tx.Commit(); err != ErrMemoryTxDone {
+		t.Fatalf("second commit err = %v, want %v", err, ErrMemoryTxDone)
+	}
+}
+
+func TestMemoryTxReadOnlyRejectsWrites(t *testing.T) {
+	client := NewMemoryClient()
+	table := MemoryTableOf[testRow](client, "rows")
+	seed(t, client, table, testRow{1, "a"})
+
+	tx, err := client.Begin(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if err = table.Insert(tx, testRow{2, "b"}); err != ErrMemoryTxReadOnly {
+		t.Errorf("insert err = %v, want %v", err, ErrMemoryTxReadOnly)
+	}
+	if _, err = table.Update(tx, func(testRow) bool { return true }, func(r *testRow) { r.value = "changed" }); err != ErrMemoryTxReadOnly {
+		t.Errorf("update err = %v, want %v", err, ErrMemoryTxReadOnly)
+	}
+	if _, err = table.Delete(tx, func(testRow) bool { return true }, 0); err != ErrMemoryTxReadOnly {
+		t.Errorf("delete err = %v, want %v", err, ErrMemoryTxReadOnly)
+	}
+
+	rows, err := table.Select(tx, func(testRow) bool { return true })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 1 || rows[0] != (testRow{1, "a"}) {
+		t.Fatalf("rows = %+v, want the seeded row untouched", rows)
+	}
+}
+
+func TestMemoryClientReadersRunTogether(t *testing.T) {
+	client := NewMemoryClient()
+
+	first, err := client.Begin(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer first.Rollback()
+
+	second, err := client.Begin(context.Background(), true)
+	if err != nil {
+		t.Fatalf("second read-only tx must not wait for the first: %v", err)
+	}
+	second.Rollback()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = client.Begin(ctx, false); err != context.Canceled {
+		t.Fatalf("write tx err = %v, want %v while a reader holds the storage", err, context.Canceled)
+	}
+}
@@ -24,6 +24,14 @@ func (d *dependencies) PostgresClient() *db.PostgresClient {
 	return d.psqlClient
 }
 
+func (d *dependencies) MemoryClient() *db.MemoryClient {
+	if d.memoryClient == nil {
+		d.memoryClient = db.NewMemoryClient()
+		d.log.Zap().Warn("storage is in memory, data will be lost on restart")
+	}
+	return d.memoryClient
+}
+
 func (d *dependencies) RabbitClient() *broker.RabbitClient {
 	if d.rabbitClient == nil {
 		var err error
@@ -1,6 +1,8 @@
 package dependencies
 
 import (
+	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -16,13 +18,25 @@ import (
 	"github.com/warehouse/auth-service/internal/handler/http"
 	"github.com/warehouse/auth-service/internal/handler/middlewares"
 	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/audit"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
 	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
 	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/service_client"
+	"github.com/warehouse/auth-service/internal/repository/operations/session"
 	transactionsRepo "github.com/warehouse/auth-service/internal/repository/operations/transactions"
 	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
+	"github.com/warehouse/auth-service/internal/scheduler"
 	"github.com/warehouse/auth-service/internal/server"
 	authSvc "github.com/warehouse/auth-service/internal/service/auth"
+	cleanupSvc "github.com/warehouse/auth-service/internal/service/cleanup"
+	clientSvc "github.com/warehouse/auth-service/internal/service/client"
 	jwtSvc "github.com/warehouse/auth-service/internal/service/jwt"
+	lockoutSvc "github.com/warehouse/auth-service/internal/service/lockout"
+	passwordSvc "github.com/warehouse/auth-service/internal/service/password"
+	patSvc "github.com/warehouse/auth-service/internal/service/pat"
 
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
@@ -37,27 +51,42 @@ type (
 
 		HttpServer() server.Server
 		GrpcServer() server.Server
+		Scheduler() scheduler.Scheduler
 	}
 
 	dependencies struct {
+		cfgPath                 string
 		cfg                     *config.Config
 		log                     logger.Logger
 		warehouseRequestHandler http.WarehouseRequestHandler
 		handlerMiddleware       middlewares.Middleware
 
 		psqlClient   *db.PostgresClient
+		memoryClient *db.MemoryClient
 		rabbitClient *broker.RabbitClient
 
-		authHandler     http.Handler
-		authGrpcHandler *grpc.AuthHandler
+		authHandler      http.Handler
+		wellKnownHandler http.Handler
+		authGrpcHandler  *grpc.AuthHandler
 
-		authService authSvc.Service
-		jwtService  jwtSvc.Service
+		authService     authSvc.Service
+		jwtService      jwtSvc.Service
+		cleanupService  cleanupSvc.Service
+		clientService   clientSvc.Service
+		patService      patSvc.Service
+		lockoutService  lockoutSvc.Service
+		passwordService passwordSvc.Service
 
-		pgxTransactionRepo    transactionsRepo.Repository
+		transactionRepo       transactionsRepo.Repository
 		jwtRepo               jwtRepo.Repository
 		verificationTokenRepo verification_token.Repository
 		resetTokenRepo        reset_token.Repository
+		sessionRepo           session.Repository
+		auditRepo             audit.Repository
+		serviceClientRepo     service_client.Repository
+		personalTokenRepo     personal_token.Repository
+		dpopRepo              dpop.Repository
+		loginFailureRepo      login_failure.Repository
 
 		timeAdapter   timeAdpt.Adapter
 		randomAdapter randomAdpt.Adapter
@@ -66,6 +95,7 @@ type (
 
 		httpServer server.Server
 		grpcServer server.Server
+		scheduler  scheduler.Scheduler
 
 		shutdownChannel chan os.Signal
 		closeCallbacks  []func()
@@ -75,7 +105,8 @@ type (
 func NewDependencies(cfgPath string) (Dependencies, error) {
 	cfg, err := config.NewConfig(cfgPath)
 	if err != nil && err.Error() == "Config File \"config\" Not Found in \"[]\"" {
-		cfg, err = config.NewConfig("./configs/local")
+		cfgPath = "./configs/local"
+		cfg, err = config.NewConfig(cfgPath)
 		if err != nil {
 			return nil, err
 		}
@@ -103,6 +134,7 @@ func NewDependencies(cfgPath string) (Dependencies, error) {
 	)
 
 	return &dependencies{
+		cfgPath:         cfgPath,
 		cfg:             cfg,
 		log:             logger.NewLogger(z),
 		shutdownChannel: make(chan os.Signal),
@@ -141,6 +173,7 @@ func (d *dependencies) HttpServer() server.Server {
 			d.cfg.Server,
 			d.HandlerMiddleware(),
 			d.AuthHandler(),
+			d.WellKnownHandler(),
 		); err != nil {
 			d.log.Zap().Panic(msg, zap.Error(err))
 		}
@@ -180,9 +213,63 @@ func (d *dependencies) GrpcServer() server.Server {
 	return d.grpcServer
 }
 
+func (d *dependencies) Scheduler() scheduler.Scheduler {
+	if d.scheduler == nil {
+		cleanup := d.CleanupService()
+		d.scheduler = scheduler.NewScheduler(
+			d.log,
+			scheduler.Job{
+				Name:     "drop_expired_tokens",
+				Interval: d.cfg.Cleanup.Interval,
+				Run: func(ctx context.Context) error {
+					if err := cleanup.DropExpired(ctx); err != nil {
+						return fmt.Errorf("%s: %w", err.Reason, err.Details)
+					}
+					return nil
+				},
+			},
+		)
+
+		d.closeCallbacks = append(d.closeCallbacks, func() {
+			msg := "shutting down scheduler"
+			if err := d.scheduler.Stop(); err != nil {
+				d.log.Zap().Warn(msg, zap.Error(err))
+				return
+			}
+			d.log.Zap().Info(msg)
+		})
+	}
+	return d.scheduler
+}
+
 func (d *dependencies) WaitForInterrupr() {
-	signal.Notify(d.shutdownChannel, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(d.shutdownChannel, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
 	d.log.Zap().Info("Wait for receive interrupt signal")
-	<-d.shutdownChannel // ждем когда сигнал запишется в канал и сразу убираем его, значит, что сигнал получен
+	// ждем когда сигнал запишется в канал и сразу убираем его, значит, что сигнал получен
+	for sig := range d.shutdownChannel {
+		if sig == syscall.SIGHUP {
+			d.reloadJwtKeys()
+			continue
+		}
+		break
+	}
 	d.log.Zap().Info("Receive interrupt signal")
 }
+
+// reloadJwtKeys перечитывает конфиг и подменяет ключи подписи jwt (ротация по SIGHUP без рестарта).
+// Ключи меняются только внутри keyring под его блокировкой, d.cfg не трогается: его читают обработчики запросов
+func (d *dependencies) reloadJwtKeys() {
+	msg := "reload jwt keys"
+	cfg, err := config.NewConfig(d.cfgPath)
+	if err != nil {
+		d.log.Zap().Error(msg, zap.Error(err))
+		return
+	}
+
+	if err := d.JwtService().RotateKeys(cfg.Auth); err != nil {
+		d.log.Zap().Error(msg, zap.Error(err))
+		return
+	}
+
+	d.log.Zap().Info(msg, zap.String("active_key", cfg.Auth.ActiveKey))
+}
@@ -10,8 +10,12 @@ func (d *dependencies) AuthHandler() http.Handler {
 		d.authHandler = http.NewAuthHandler(
 			d.cfg.Server,
 			d.cfg.Timeouts,
+			d.cfg.Auth.ServiceAudience,
 			d.JwtService(),
 			d.AuthService(),
+			d.ClientService(),
+			d.PatService(),
+			d.LockoutService(),
 			d.TimeAdapter(),
 			d.UserAdapter(),
 			d.WarehouseJsonRequestHandler(),
@@ -22,6 +26,17 @@ func (d *dependencies) AuthHandler() http.Handler {
 	return d.authHandler
 }
 
+func (d *dependencies) WellKnownHandler() http.Handler {
+	if d.wellKnownHandler == nil {
+		d.wellKnownHandler = http.NewWellKnownHandler(
+			d.JwtService(),
+			d.WarehouseJsonRequestHandler(),
+		)
+	}
+
+	return d.wellKnownHandler
+}
+
 func (d *dependencies) AuthGrpcHandler() *grpc.AuthHandler {
 	if d.authGrpcHandler == nil {
 		d.authGrpcHandler = grpc.NewAuthHandler(
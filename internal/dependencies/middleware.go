@@ -7,7 +7,12 @@ func (d *dependencies) HandlerMiddleware() middlewares.Middleware {
 		d.handlerMiddleware = middlewares.NewMiddleware(
 			d.log,
 			d.cfg.Timeouts,
+			d.cfg.ServiceAuth,
+			d.cfg.Server.PublicUrl,
+			d.cfg.Auth.ServiceAudience,
 			d.JwtService(),
+			d.ClientService(),
+			d.TimeAdapter(),
 		)
 	}
 
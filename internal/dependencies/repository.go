@@ -1,29 +1,53 @@
 package dependencies
 
 import (
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/repository/operations/audit"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
 	"github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
 	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/service_client"
+	"github.com/warehouse/auth-service/internal/repository/operations/session"
 	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
 	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
 )
 
-func (d *dependencies) PgxTransactionRepo() transactions.Repository {
-	if d.pgxTransactionRepo == nil {
-		d.pgxTransactionRepo = transactions.NewPgxRepository(d.PostgresClient())
+// memoryStorage репозитории в памяти вместо postgres (storage=memory)
+func (d *dependencies) memoryStorage() bool {
+	return d.cfg.Storage == config.StorageMemory
+}
+
+func (d *dependencies) TransactionRepo() transactions.Repository {
+	if d.transactionRepo == nil {
+		if d.memoryStorage() {
+			d.transactionRepo = transactions.NewMemoryRepository(d.MemoryClient())
+		} else {
+			d.transactionRepo = transactions.NewPgxRepository(d.PostgresClient())
+		}
 	}
-	return d.pgxTransactionRepo
+	return d.transactionRepo
 }
 
 func (d *dependencies) JwtRepo() jwt.Repository {
 	if d.jwtRepo == nil {
-		d.jwtRepo = jwt.NewPGRepository(d.log, d.PostgresClient())
+		if d.memoryStorage() {
+			d.jwtRepo = jwt.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.jwtRepo = jwt.NewPGRepository(d.log, d.PostgresClient())
+		}
 	}
 	return d.jwtRepo
 }
 
 func (d *dependencies) VerificationTokenRepo() verification_token.Repository {
 	if d.verificationTokenRepo == nil {
-		d.verificationTokenRepo = verification_token.NewPGRepository(d.log, d.PostgresClient())
+		if d.memoryStorage() {
+			d.verificationTokenRepo = verification_token.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.verificationTokenRepo = verification_token.NewPGRepository(d.log, d.PostgresClient())
+		}
 	}
 
 	return d.verificationTokenRepo
@@ -31,8 +55,84 @@ func (d *dependencies) VerificationTokenRepo() verification_token.Repository {
 
 func (d *dependencies) ResetTokenRepo() reset_token.Repository {
 	if d.resetTokenRepo == nil {
-		d.resetTokenRepo = reset_token.NewPGRepository(d.log, d.PostgresClient())
+		if d.memoryStorage() {
+			d.resetTokenRepo = reset_token.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.resetTokenRepo = reset_token.NewPGRepository(d.log, d.PostgresClient())
+		}
 	}
 
 	return d.resetTokenRepo
 }
+
+func (d *dependencies) SessionRepo() session.Repository {
+	if d.sessionRepo == nil {
+		if d.memoryStorage() {
+			d.sessionRepo = session.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.sessionRepo = session.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.sessionRepo
+}
+
+func (d *dependencies) AuditRepo() audit.Repository {
+	if d.auditRepo == nil {
+		if d.memoryStorage() {
+			d.auditRepo = audit.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.auditRepo = audit.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.auditRepo
+}
+
+func (d *dependencies) ServiceClientRepo() service_client.Repository {
+	if d.serviceClientRepo == nil {
+		if d.memoryStorage() {
+			d.serviceClientRepo = service_client.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.serviceClientRepo = service_client.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.serviceClientRepo
+}
+
+func (d *dependencies) PersonalTokenRepo() personal_token.Repository {
+	if d.personalTokenRepo == nil {
+		if d.memoryStorage() {
+			d.personalTokenRepo = personal_token.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.personalTokenRepo = personal_token.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.personalTokenRepo
+}
+
+func (d *dependencies) DPoPRepo() dpop.Repository {
+	if d.dpopRepo == nil {
+		if d.memoryStorage() {
+			d.dpopRepo = dpop.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.dpopRepo = dpop.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.dpopRepo
+}
+
+func (d *dependencies) LoginFailureRepo() login_failure.Repository {
+	if d.loginFailureRepo == nil {
+		if d.memoryStorage() {
+			d.loginFailureRepo = login_failure.NewMemoryRepository(d.log, d.MemoryClient())
+		} else {
+			d.loginFailureRepo = login_failure.NewPGRepository(d.log, d.PostgresClient())
+		}
+	}
+
+	return d.loginFailureRepo
+}
@@ -2,14 +2,21 @@ package dependencies
 
 import (
 	"github.com/warehouse/auth-service/internal/service/auth"
+	"github.com/warehouse/auth-service/internal/service/cleanup"
+	"github.com/warehouse/auth-service/internal/service/client"
 	"github.com/warehouse/auth-service/internal/service/jwt"
+	"github.com/warehouse/auth-service/internal/service/lockout"
+	"github.com/warehouse/auth-service/internal/service/password"
+	"github.com/warehouse/auth-service/internal/service/pat"
+
+	"go.uber.org/zap"
 )
 
 func (d *dependencies) AuthService() auth.Service {
 	if d.authService == nil {
 		d.authService = auth.NewService(
 			*d.cfg,
-			d.PgxTransactionRepo(),
+			d.TransactionRepo(),
 			d.JwtRepo(),
 			d.TimeAdapter(),
 			d.JwtService(),
@@ -18,6 +25,10 @@ func (d *dependencies) AuthService() auth.Service {
 			d.MailAdapter(),
 			d.VerificationTokenRepo(),
 			d.ResetTokenRepo(),
+			d.AuditRepo(),
+			d.PersonalTokenRepo(),
+			d.LockoutService(),
+			d.PasswordService(),
 		)
 	}
 
@@ -26,15 +37,97 @@ func (d *dependencies) AuthService() auth.Service {
 
 func (d *dependencies) JwtService() jwt.Service {
 	if d.jwtService == nil {
-		d.jwtService = jwt.NewService(
+		var err error
+		if d.jwtService, err = jwt.NewService(
 			d.log,
-			d.PgxTransactionRepo(),
+			d.TransactionRepo(),
 			d.JwtRepo(),
+			d.SessionRepo(),
+			d.PersonalTokenRepo(),
+			d.DPoPRepo(),
 			d.cfg.Auth,
 			d.TimeAdapter(),
 			d.RandomAdapter(),
-		)
+		); err != nil {
+			d.log.Zap().Panic("create jwt service", zap.Error(err))
+		}
 	}
 
 	return d.jwtService
 }
+
+func (d *dependencies) CleanupService() cleanup.Service {
+	if d.cleanupService == nil {
+		d.cleanupService = cleanup.NewService(
+			d.log,
+			d.cfg.Cleanup,
+			d.TransactionRepo(),
+			d.JwtRepo(),
+			d.VerificationTokenRepo(),
+			d.ResetTokenRepo(),
+			d.SessionRepo(),
+			d.PersonalTokenRepo(),
+			d.DPoPRepo(),
+			d.LoginFailureRepo(),
+			d.TimeAdapter(),
+		)
+	}
+
+	return d.cleanupService
+}
+
+func (d *dependencies) ClientService() client.Service {
+	if d.clientService == nil {
+		d.clientService = client.NewService(
+			d.log,
+			d.cfg.Auth,
+			d.TransactionRepo(),
+			d.ServiceClientRepo(),
+			d.JwtRepo(),
+			d.JwtService(),
+			d.TimeAdapter(),
+		)
+	}
+
+	return d.clientService
+}
+
+func (d *dependencies) PatService() pat.Service {
+	if d.patService == nil {
+		d.patService = pat.NewService(
+			d.log,
+			d.TransactionRepo(),
+			d.PersonalTokenRepo(),
+			d.TimeAdapter(),
+		)
+	}
+
+	return d.patService
+}
+
+func (d *dependencies) LockoutService() lockout.Service {
+	if d.lockoutService == nil {
+		d.lockoutService = lockout.NewService(
+			d.log,
+			d.cfg.Lockout,
+			d.TransactionRepo(),
+			d.LoginFailureRepo(),
+			d.TimeAdapter(),
+			d.UserAdapter(),
+			d.MailAdapter(),
+		)
+	}
+
+	return d.lockoutService
+}
+
+func (d *dependencies) PasswordService() password.Service {
+	if d.passwordService == nil {
+		var err error
+		if d.passwordService, err = password.NewService(d.log, d.cfg.Password); err != nil {
+			d.log.Zap().Panic("create password service", zap.Error(err))
+		}
+	}
+
+	return d.passwordService
+}
@@ -1,7 +1,7 @@
 package domain
 
 var (
-	Roles = []Role{RoleAdmin, RoleUser}
+	Roles = []Role{RoleAdmin, RoleUser, RoleService}
 )
 
 type Role int64
@@ -9,6 +9,30 @@ type Role int64
 const (
 	RoleAdmin Role = iota
 	RoleUser
+	// RoleService сервисный аккаунт, получает токены через client_credentials
+	RoleService
+)
+
+type PrincipalType int64
+
+const (
+	PrincipalUser = PrincipalType(iota)
+	PrincipalService
+)
+
+// PrincipalType человек или сервис стоит за токеном
+func (r Role) PrincipalType() PrincipalType {
+	if r == RoleService {
+		return PrincipalService
+	}
+	return PrincipalUser
+}
+
+// Способы входа для claim amr (RFC 8176)
+const (
+	AmrPassword = "pwd"
+	// AmrEmail вход по ссылке из письма подтверждения
+	AmrEmail = "email"
 )
 
 type AuthPurpose int64
@@ -33,6 +57,32 @@ type (
 		ExpiresAt int64  `json:"expires_at"`
 	}
 
+	// TokenClaims проверенные клеймы токена
+	TokenClaims struct {
+		Id        string
+		Issuer    string
+		Audience  []string
+		UserId    string
+		Role      Role
+		Purpose   AuthPurpose
+		Number    int64
+		IssuedAt  int64
+		NotBefore int64
+		ExpiresAt int64
+		// Actor айди админа, выпустившего токен от имени пользователя (claim act). Пусто для обычных токенов
+		Actor string
+		// Scopes права токена (claim scope)
+		Scopes []string
+		// PersonalTokenId айди персонального токена, если запрос пришел с ним вместо jwt
+		PersonalTokenId string
+		// Jkt отпечаток DPoP ключа из claim cnf. Такой токен принимается только вместе с DPoP proof
+		Jkt string
+		// AuthTime время интерактивного входа в секундах (claim auth_time). 0 - токен выпущен без входа пользователя
+		AuthTime int64
+		// Amr способы входа (claim amr)
+		Amr []string
+	}
+
 	VerificationTokenInfo struct {
 		ID        string
 		UserId    string
@@ -49,6 +99,12 @@ type (
 		ExpiresAt int64
 		CreatedAt int64
 	}
+
+	// Credentials аккаунт и bcrypt хеш его пароля, хеш нужен только для проверки при логине
+	Credentials struct {
+		Account Account
+		Hash    string
+	}
 )
 
 //Request
@@ -0,0 +1,11 @@
+package domain
+
+type (
+	// ServiceClient сервисный аккаунт. Секрет хранится только в виде хеша
+	ServiceClient struct {
+		Id        string
+		Name      string
+		Scopes    []string
+		CreatedAt int64
+	}
+)
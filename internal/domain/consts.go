@@ -13,6 +13,11 @@ const (
 
 	S3Endpoint = "storage.yandexcloud.net"
 
-	AccountCtxKey     = CtxKey("account")
-	TokenNumberCtxKey = CtxKey("token_number")
+	AccountCtxKey       = CtxKey("account")
+	TokenNumberCtxKey   = CtxKey("token_number")
+	ServiceClientCtxKey = CtxKey("service_client")
+	ActorCtxKey         = CtxKey("actor")
+	ScopesCtxKey        = CtxKey("scopes")
+	PersonalTokenCtxKey = CtxKey("personal_token")
+	AuthTimeCtxKey      = CtxKey("auth_time")
 )
@@ -0,0 +1,32 @@
+package domain
+
+type (
+	// JWK публичный ключ в формате RFC 7517
+	JWK struct {
+		Kty string `json:"kty"`
+		Use string `json:"use,omitempty"`
+		Alg string `json:"alg,omitempty"`
+		Kid string `json:"kid,omitempty"`
+
+		// RSA
+		N string `json:"n,omitempty"`
+		E string `json:"e,omitempty"`
+
+		// EC / OKP
+		Crv string `json:"crv,omitempty"`
+		X   string `json:"x,omitempty"`
+		Y   string `json:"y,omitempty"`
+	}
+
+	JWKS struct {
+		Keys []JWK `json:"keys"`
+	}
+
+	// DPoPRequest запрос, для которого клиент подписал DPoP proof
+	DPoPRequest struct {
+		Method string
+		Url    string
+		// AccessToken токен, с которым пришел запрос. Пусто при логине и рефреше
+		AccessToken string
+	}
+)
@@ -0,0 +1,7 @@
+package domain
+
+// счетчики неудачных входов ведутся отдельно по логину и по ip клиента
+const (
+	LockoutScopeAccount = "account"
+	LockoutScopeIp      = "ip"
+)
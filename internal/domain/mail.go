@@ -5,6 +5,10 @@ type EmailType string
 const (
 	VerificationType EmailType = "verification_email"
 	ResetType        EmailType = "reset_type"
+	// PasswordChangedType уведомление о смене пароля, без payload кроме имени
+	PasswordChangedType EmailType = "password_changed"
+	// AccountLockedType уведомление о блокировке входа после неудачных попыток
+	AccountLockedType EmailType = "account_locked"
 )
 
 type (
@@ -18,6 +22,7 @@ type (
 		Firstname     string        `json:"firstname"`
 		ResetPayload  ResetPayload  `json:"reset_payload"`
 		VerifyPayload VerifyPayload `json:"verify_payload"`
+		LockPayload   LockPayload   `json:"lock_payload"`
 	}
 
 	ResetPayload struct {
@@ -29,4 +34,9 @@ type (
 	VerifyPayload struct {
 		Token string `json:"token"`
 	}
+
+	// LockPayload LockedUntil - unix время в секундах
+	LockPayload struct {
+		LockedUntil int64 `json:"locked_until"`
+	}
 )
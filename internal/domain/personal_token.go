@@ -0,0 +1,16 @@
+package domain
+
+// PersonalTokenPrefix отличает персональные токены от jwt, чтобы не пытаться их парсить
+const PersonalTokenPrefix = "whpat_"
+
+type (
+	// PersonalToken долгоживущий токен для скриптов. Сам токен хранится только в виде хеша, время в миллисекундах
+	PersonalToken struct {
+		Id         string
+		Name       string
+		Scopes     []string
+		CreatedAt  int64
+		ExpiresAt  int64
+		LastUsedAt int64
+	}
+)
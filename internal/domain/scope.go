@@ -0,0 +1,14 @@
+package domain
+
+// ScopeSessionsManage просмотр и закрытие своих сессий
+const ScopeSessionsManage = "sessions:manage"
+
+// HasScope есть ли scope среди выданных скоупов
+func HasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
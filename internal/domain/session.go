@@ -0,0 +1,37 @@
+package domain
+
+type (
+	// SessionMeta откуда открыта сессия, пишется при логине
+	SessionMeta struct {
+		Ip        string
+		UserAgent string
+		// Scopes запрошенное сужение прав сессии. Пусто - все скоупы роли
+		Scopes []string
+		// Jkt отпечаток DPoP ключа клиента (RFC 9449). Пусто - токены не привязываются к ключу
+		Jkt string
+		// Amr чем пользователь подтвердил вход (RFC 8176): AmrPassword, AmrEmail
+		Amr []string
+		// ActorId кто действует от имени пользователя. Заполнен только у сессий имперсонации
+		ActorId string
+	}
+
+	// Session сессия пользователя, number совпадает с номером пары токенов. Время в миллисекундах
+	Session struct {
+		Role       Role
+		UserId     string
+		Number     int64
+		Ip         string
+		UserAgent  string
+		CreatedAt  int64
+		LastUsedAt int64
+		// Scopes сужение прав сессии, выбранное при логине. Пусто - все скоупы роли
+		Scopes []string
+		// Jkt отпечаток DPoP ключа, к которому привязаны токены сессии
+		Jkt string
+		// AuthTime время интерактивного входа, не меняется при рефреше
+		AuthTime int64
+		Amr      []string
+		// ActorId админ, открывший сессию имперсонации
+		ActorId string
+	}
+)
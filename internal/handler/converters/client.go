@@ -0,0 +1,23 @@
+package converters
+
+import (
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+)
+
+func DomainServiceClient2ServiceClient(client domain.ServiceClient) models.ServiceClient {
+	return models.ServiceClient{
+		Id:        client.Id,
+		Name:      client.Name,
+		Scopes:    client.Scopes,
+		CreatedAt: client.CreatedAt,
+	}
+}
+
+func DomainServiceClients2ServiceClients(clients []domain.ServiceClient) []models.ServiceClient {
+	res := make([]models.ServiceClient, 0, len(clients))
+	for _, client := range clients {
+		res = append(res, DomainServiceClient2ServiceClient(client))
+	}
+	return res
+}
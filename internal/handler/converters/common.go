@@ -10,8 +10,9 @@ import (
 
 func MakeJsonErrorResponseWithErrorsError(err *errors.Error) models.ErrorResponse {
 	res := models.ErrorResponse{
-		Code:   err.Code,
-		Reason: err.Reason,
+		Code:    err.Code,
+		Reason:  err.Reason,
+		Payload: err.Payload,
 	}
 
 	if err.Details != nil {
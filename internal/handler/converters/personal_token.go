@@ -0,0 +1,25 @@
+package converters
+
+import (
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+)
+
+func DomainPersonalToken2PersonalToken(token domain.PersonalToken) models.PersonalToken {
+	return models.PersonalToken{
+		Id:         token.Id,
+		Name:       token.Name,
+		Scopes:     token.Scopes,
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
+
+func DomainPersonalTokens2PersonalTokens(tokens []domain.PersonalToken) []models.PersonalToken {
+	res := make([]models.PersonalToken, 0, len(tokens))
+	for _, token := range tokens {
+		res = append(res, DomainPersonalToken2PersonalToken(token))
+	}
+	return res
+}
@@ -0,0 +1,31 @@
+package converters
+
+import (
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+)
+
+// NoCurrentSession номер для запроса не из сессии, например с персональным токеном. Номера сессий начинаются с нуля
+const NoCurrentSession int64 = -1
+
+// DomainSession2Session current номер сессии, из которой сделан запрос, или NoCurrentSession
+func DomainSession2Session(session domain.Session, current int64) models.Session {
+	return models.Session{
+		Number:     session.Number,
+		Ip:         session.Ip,
+		UserAgent:  session.UserAgent,
+		CreatedAt:  session.CreatedAt,
+		LastUsedAt: session.LastUsedAt,
+		Current:    session.Number == current,
+		Scopes:     session.Scopes,
+		ActorId:    session.ActorId,
+	}
+}
+
+func DomainSessions2Sessions(sessions []domain.Session, current int64) []models.Session {
+	res := make([]models.Session, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, DomainSession2Session(session, current))
+	}
+	return res
+}
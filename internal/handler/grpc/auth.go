@@ -45,11 +45,28 @@ func (s *AuthHandler) Authenticate(ctx context.Context, req *warehousepb.AuthReq
 	ctx, cancel = context.WithTimeout(ctx, s.timeouts.RequestTimeout)
 	defer cancel()
 
-	acc, num, err := s.jwtSvc.Auth(ctx, req.Token, domain.AuthPurpose(req.Purpose))
+	acc, claims, err := s.jwtSvc.Auth(ctx, req.Token, domain.AuthPurpose(req.Purpose), req.Audience)
 
 	if err != nil {
 		return nil, handler_converters.MakeStatusFromErrorsError(err)
 	}
 
-	return &warehousepb.AuthResponse{User: converters.DomainUser2ProtoAccount(acc), Number: num}, nil
+	// сервис пересылает DPoP proof и метод с адресом исходного запроса, для которых proof подписан
+	if err = s.jwtSvc.CheckDPoPBinding(ctx, claims, req.DpopProof, domain.DPoPRequest{
+		Method:      req.HttpMethod,
+		Url:         req.HttpUrl,
+		AccessToken: req.Token,
+	}); err != nil {
+		return nil, handler_converters.MakeStatusFromErrorsError(err)
+	}
+
+	return &warehousepb.AuthResponse{
+		User:          converters.DomainUser2ProtoAccount(acc),
+		Number:        claims.Number,
+		ActorId:       claims.Actor,
+		Scopes:        claims.Scopes,
+		PrincipalType: converters.DomainPrincipalType2Proto(acc.Role.PrincipalType()),
+		AuthTime:      claims.AuthTime,
+		Amr:           claims.Amr,
+	}, nil
 }
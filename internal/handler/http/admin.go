@@ -0,0 +1,67 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/gorilla/mux"
+)
+
+// impersonateHandler токен от имени пользователя для поддержки. Доступен только админам
+func (h *authHandler) impersonateHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	var req models.ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
+	}
+
+	meta := h.sessionMeta(r)
+	meta.Scopes = strings.Fields(req.Scope)
+
+	token, err := h.authService.Impersonate(ctx, *acc, req, meta)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.ImpersonateResponse{
+			AccessToken: token,
+		},
+		http.StatusOK,
+		nil,
+	)
+}
+
+// unlockHandler снимает блокировку логина после неудачных входов. Доступен только админам
+func (h *authHandler) unlockHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := h.lockoutService.Unlock(ctx, *acc, mux.Vars(r)["login"]); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusNoContent,
+		nil,
+	)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"net/http"
+	"strings"
 
 	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
 	userAdpt "github.com/warehouse/auth-service/internal/adapter/user"
@@ -13,7 +14,10 @@ import (
 	"github.com/warehouse/auth-service/internal/handler/models"
 	"github.com/warehouse/auth-service/internal/pkg/errors"
 	"github.com/warehouse/auth-service/internal/service/auth"
+	"github.com/warehouse/auth-service/internal/service/client"
 	"github.com/warehouse/auth-service/internal/service/jwt"
+	"github.com/warehouse/auth-service/internal/service/lockout"
+	"github.com/warehouse/auth-service/internal/service/pat"
 
 	"github.com/gorilla/mux"
 )
@@ -22,9 +26,14 @@ type (
 	authHandler struct {
 		cfg      *config.Server
 		timeouts *config.Timeouts
+		// audience аудитория auth сервиса для интроспекции
+		audience string
 
-		jwtService  jwt.Service
-		authService auth.Service
+		jwtService     jwt.Service
+		authService    auth.Service
+		clientService  client.Service
+		patService     pat.Service
+		lockoutService lockout.Service
 
 		timeAdapter timeAdpt.Adapter
 		userAdapter userAdpt.Adapter
@@ -37,9 +46,13 @@ type (
 func NewAuthHandler(
 	cfg config.Server,
 	timeouts config.Timeouts,
+	audience string,
 
 	jwtSvc jwt.Service,
 	authSvc auth.Service,
+	clientSvc client.Service,
+	patSvc pat.Service,
+	lockoutSvc lockout.Service,
 
 	timeAdpt timeAdpt.Adapter,
 	userAdpt userAdpt.Adapter,
@@ -50,9 +63,13 @@ func NewAuthHandler(
 	return &authHandler{
 		cfg:      &cfg,
 		timeouts: &timeouts,
+		audience: audience,
 
-		jwtService:  jwtSvc,
-		authService: authSvc,
+		jwtService:     jwtSvc,
+		authService:    authSvc,
+		clientService:  clientSvc,
+		patService:     patSvc,
+		lockoutService: lockoutSvc,
 
 		timeAdapter: timeAdpt,
 		userAdapter: userAdpt,
@@ -69,30 +86,53 @@ func (h *authHandler) FillHandlers(router *mux.Router) {
 	base := "/auth"
 	r := router.PathPrefix(base).Subrouter()
 	h.reqHandler.HandleJsonRequest(r, base, "", http.MethodPost, h.loginHandler)
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/full_logout", http.MethodDelete, h.fullLogoutHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/logout", http.MethodDelete, h.logoutHandler, h.middleware.JwtAuthMiddleware(domain.PurposeAccess))
-	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/refresh", http.MethodGet, h.refreshHandler, h.middleware.JwtAuthMiddleware(domain.PurposeRefresh))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/full_logout", http.MethodDelete, h.fullLogoutHandler, h.middleware.JwtSessionMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/logout", http.MethodDelete, h.logoutHandler, h.middleware.JwtSessionMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequest(r, base, "/refresh", http.MethodGet, h.refreshHandler)
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/sessions", http.MethodGet, h.sessionsHandler, h.middleware.JwtScopeMiddleware(domain.PurposeAccess, domain.ScopeSessionsManage))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/sessions/{number}", http.MethodDelete, h.revokeSessionHandler, h.middleware.JwtScopeMiddleware(domain.PurposeAccess, domain.ScopeSessionsManage))
 	h.reqHandler.HandleJsonRequest(r, base, "/register", http.MethodPost, h.registerHandler)
 	h.reqHandler.HandleJsonRequest(r, base, "/verify/check", http.MethodGet, h.checkVerificationToken)
 	h.reqHandler.HandleJsonRequest(r, base, "/reset/request", http.MethodGet, h.resetPasswordRequest)
 	h.reqHandler.HandleJsonRequest(r, base, "/reset/confirm", http.MethodPost, h.resetPasswordConfirm)
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/password", http.MethodPost, h.changePasswordHandler, h.middleware.JwtRecentAuthMiddleware(domain.PurposeAccess, h.timeouts.StepUp))
+	h.reqHandler.HandleJsonRequest(r, base, "/revoke", http.MethodPost, h.revokeHandler)
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/introspect", http.MethodPost, h.introspectHandler, h.middleware.ServiceAuthMiddleware)
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/impersonate", http.MethodPost, h.impersonateHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/lockouts/{login}", http.MethodDelete, h.unlockHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
+	h.reqHandler.HandleJsonRequest(r, base, "/token", http.MethodPost, h.tokenHandler)
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/tokens", http.MethodPost, h.createPersonalTokenHandler, h.middleware.JwtRecentAuthMiddleware(domain.PurposeAccess, h.timeouts.StepUp))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/tokens", http.MethodGet, h.personalTokensHandler, h.middleware.JwtSessionMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/tokens/{id}", http.MethodDelete, h.revokePersonalTokenHandler, h.middleware.JwtSessionMiddleware(domain.PurposeAccess))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/clients", http.MethodPost, h.createClientHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/clients", http.MethodGet, h.clientsHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/clients/{id}", http.MethodDelete, h.deleteClientHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
+	h.reqHandler.HandleJsonRequestWithMiddleware(r, base, "/clients/{id}/secret", http.MethodPost, h.rotateClientSecretHandler, h.middleware.JwtRoleMiddleware(domain.PurposeAccess, domain.RoleAdmin))
 }
 
+// refreshHandler сам разбирает refresh токен: проверка, ротация и обнаружение повторного использования
+// должны идти в одной транзакции, поэтому JwtAuthMiddleware тут не используется
 func (h *authHandler) refreshHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
-	if acc == nil {
+	token, _, ok := middlewares.AuthToken(r)
+	if !ok {
 		return whJsonErrorResponse(errors.AuthAuthFailed)
 	}
-	number := ctx.Value(domain.TokenNumberCtxKey).(int64)
 
 	var cancel func()
 	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
 	defer cancel()
-	newAt, newRt, err := h.jwtService.ReCreateTokens(ctx, acc.Role, acc.Id, number)
+
+	jkt, err := h.dpopKey(ctx, r)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
 
-	accCookie, err := createCookie("acc", acc)
+	refreshedAcc, newAt, newRt, err := h.jwtService.ReCreateTokens(ctx, token, jkt)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	accCookie, err := createCookie("acc", refreshedAcc)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
@@ -131,12 +171,16 @@ func (h *authHandler) logoutHandler(ctx context.Context, acc *domain.Account, r
 	if acc == nil {
 		return whJsonErrorResponse(errors.AuthAuthFailed)
 	}
+	number, ok := ctx.Value(domain.TokenNumberCtxKey).(int64)
+	if !ok {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
 
 	var cancel func()
 	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
 	defer cancel()
 
-	if err := h.jwtService.Logout(ctx, acc.Role, acc.Id); err != nil {
+	if err := h.jwtService.DropTokens(ctx, acc.Role, acc.Id, number); err != nil {
 		return whJsonErrorResponse(err)
 	}
 
@@ -156,7 +200,17 @@ func (h *authHandler) loginHandler(ctx context.Context, acc *domain.Account, r *
 		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
 	}
 
-	acc, at, rt, err := h.authService.Login(ctx, req)
+	meta := h.sessionMeta(r)
+
+	jkt, err := h.dpopKey(ctx, r)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	meta.Scopes = strings.Fields(req.Scope)
+	meta.Jkt = jkt
+
+	acc, at, rt, err := h.authService.Login(ctx, req, meta)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
@@ -208,12 +262,20 @@ func (h *authHandler) checkVerificationToken(ctx context.Context, acc *domain.Ac
 	accId := vars["acc_id"]
 	tokenId := vars["token_id"]
 
+	jkt, err := h.dpopKey(ctx, r)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
 	existAcc, err := h.authService.CheckVerificationToken(ctx, plainVerificationToken, accId, tokenId)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
 
-	accessToken, refreshToken, err := h.jwtService.CreateTokens(ctx, existAcc.Role, accId)
+	meta := h.sessionMeta(r)
+	meta.Jkt = jkt
+	meta.Amr = []string{domain.AmrEmail}
+	accessToken, refreshToken, err := h.jwtService.CreateTokens(ctx, existAcc.Role, accId, meta)
 	if err != nil {
 		return whJsonErrorResponse(err)
 	}
@@ -259,7 +321,7 @@ func (h *authHandler) resetPasswordConfirm(ctx context.Context, acc *domain.Acco
 		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
 	}
 
-	if err := h.authService.VerifyResetToken(ctx, req.Token, req.TokenId, req.AccId); err != nil {
+	if err := h.authService.ResetPassword(ctx, req); err != nil {
 		return whJsonErrorResponse(err)
 	}
 
@@ -269,3 +331,43 @@ func (h *authHandler) resetPasswordConfirm(ctx context.Context, acc *domain.Acco
 		nil,
 	)
 }
+
+// changePasswordHandler смена пароля по текущему паролю. Требует недавнего входа, после смены нужно войти заново
+func (h *authHandler) changePasswordHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	var req models.PasswordChangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
+	}
+
+	if err := h.authService.ChangePassword(ctx, *acc, req, h.sessionMeta(r)); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusNoContent,
+		nil,
+	)
+}
+
+// dpopKey проверяет необязательный DPoP proof при выдаче токенов и возвращает отпечаток ключа.
+// Без заголовка DPoP выдаются обычные bearer токены
+func (h *authHandler) dpopKey(ctx context.Context, r *http.Request) (string, *errors.Error) {
+	proof := r.Header.Get(middlewares.DPoPHeader)
+	if proof == "" {
+		return "", nil
+	}
+
+	return h.jwtService.VerifyDPoPProof(ctx, proof, domain.DPoPRequest{
+		Method: r.Method,
+		Url:    middlewares.RequestUrl(r, h.cfg.PublicUrl),
+	})
+}
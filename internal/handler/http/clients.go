@@ -0,0 +1,91 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/converters"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/gorilla/mux"
+)
+
+// createClientHandler регистрирует сервисный клиент. Секрет возвращается только в этом ответе
+func (h *authHandler) createClientHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	var req models.CreateClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
+	}
+
+	client, secret, err := h.clientService.Create(ctx, req)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.CreateClientResponse{
+			ServiceClient: converters.DomainServiceClient2ServiceClient(client),
+			ClientSecret:  secret,
+		},
+		http.StatusCreated,
+		nil,
+	)
+}
+
+func (h *authHandler) clientsHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	clients, err := h.clientService.List(ctx)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.ClientsResponse{Clients: converters.DomainServiceClients2ServiceClients(clients)},
+		http.StatusOK,
+		nil,
+	)
+}
+
+// deleteClientHandler удаляет клиент и отзывает все его токены
+func (h *authHandler) deleteClientHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := h.clientService.Delete(ctx, mux.Vars(r)["id"]); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusNoContent,
+		nil,
+	)
+}
+
+func (h *authHandler) rotateClientSecretHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	secret, err := h.clientService.RotateSecret(ctx, mux.Vars(r)["id"])
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.ClientSecretResponse{ClientSecret: secret},
+		http.StatusOK,
+		nil,
+	)
+}
@@ -58,6 +58,12 @@ func NewWarehouseJsonRequestHandler(
 }
 
 func whJsonErrorResponse(err *errors.Error) jsonResponse {
+	// сервисы отдают в payload доменные сессии, клиент получает их в формате /sessions.
+	// Отказ выдается при входе, текущей сессии у запроса нет
+	if sessions, ok := err.Payload.([]domain.Session); ok {
+		err = errors.WP(err, converters.DomainSessions2Sessions(sessions, converters.NoCurrentSession))
+	}
+
 	return jsonResponse{
 		Code:  int(err.Code),
 		Error: err,
@@ -0,0 +1,178 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+)
+
+const (
+	accessTokenTypeHint  = "access_token"
+	refreshTokenTypeHint = "refresh_token"
+
+	grantTypeClientCredentials = "client_credentials"
+	grantTypeTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+	accessTokenType            = "urn:ietf:params:oauth:token-type:access_token"
+	bearerTokenType            = "Bearer"
+)
+
+// introspectHandler RFC 7662. Невалидный, просроченный или отозванный токен - это active=false, а не ошибка
+func (h *authHandler) introspectHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	ctx, cancel := context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.ParseError, err))
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		return whJsonErrorResponse(errors.WD(errors.ValidationFailed, errors.New("token is required")))
+	}
+
+	purposes := []domain.AuthPurpose{domain.PurposeAccess, domain.PurposeRefresh}
+	if r.PostForm.Get("token_type_hint") == refreshTokenTypeHint {
+		purposes = []domain.AuthPurpose{domain.PurposeRefresh, domain.PurposeAccess}
+	}
+
+	for _, purpose := range purposes {
+		tokenAcc, claims, err := h.jwtService.Auth(ctx, token, purpose, h.audience)
+		if err != nil {
+			if err.Code >= http.StatusInternalServerError {
+				return whJsonErrorResponse(err)
+			}
+			continue
+		}
+
+		role, number := int64(tokenAcc.Role), claims.Number
+		tokenType := accessTokenTypeHint
+		if purpose == domain.PurposeRefresh {
+			tokenType = refreshTokenTypeHint
+		}
+		var act *models.Actor
+		if claims.Actor != "" {
+			act = &models.Actor{Sub: claims.Actor}
+		}
+		var cnf *models.Cnf
+		if claims.Jkt != "" {
+			cnf = &models.Cnf{Jkt: claims.Jkt}
+		}
+
+		return whJsonSuccessResponse(
+			models.IntrospectionResponse{
+				Active:    true,
+				Sub:       tokenAcc.Id,
+				Exp:       claims.ExpiresAt,
+				Iat:       claims.IssuedAt,
+				Nbf:       claims.NotBefore,
+				Iss:       claims.Issuer,
+				Aud:       claims.Audience,
+				Jti:       claims.Id,
+				TokenType: tokenType,
+				Role:      &role,
+				Number:    &number,
+				Act:       act,
+				Scope:     strings.Join(claims.Scopes, " "),
+				Cnf:       cnf,
+				AuthTime:  claims.AuthTime,
+				Amr:       claims.Amr,
+			},
+			http.StatusOK,
+			nil,
+		)
+	}
+
+	return whJsonSuccessResponse(
+		models.IntrospectionResponse{Active: false},
+		http.StatusOK,
+		nil,
+	)
+}
+
+// revokeHandler RFC 7009. Отвечает 200 и на невалидный или уже отозванный токен
+func (h *authHandler) revokeHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	ctx, cancel := context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.ParseError, err))
+	}
+
+	token := r.PostForm.Get("token")
+	if token == "" {
+		return whJsonErrorResponse(errors.WD(errors.ValidationFailed, errors.New("token is required")))
+	}
+
+	if err := h.jwtService.Revoke(ctx, token); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusOK,
+		nil,
+	)
+}
+
+// tokenHandler токен эндпоинт RFC 6749. Поддерживаются client_credentials и token exchange (RFC 8693) для сервисных клиентов
+func (h *authHandler) tokenHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	ctx, cancel := context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := r.ParseForm(); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.ParseError, err))
+	}
+
+	clientId, secret, ok := r.BasicAuth()
+	if !ok {
+		clientId, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	var (
+		token           domain.JwtTokenInfo
+		scopes          []string
+		issuedTokenType string
+		err             *errors.Error
+	)
+	switch grantType := r.PostForm.Get("grant_type"); grantType {
+	case grantTypeClientCredentials:
+		token, scopes, err = h.clientService.IssueToken(ctx, clientId, secret, r.PostForm.Get("scope"), h.sessionMeta(r))
+	case grantTypeTokenExchange:
+		// обменять можно только access токен и только на access токен
+		if tokenType := r.PostForm.Get("subject_token_type"); tokenType != accessTokenType {
+			return whJsonErrorResponse(errors.WD(errors.ValidationFailed, fmt.Errorf("subject_token_type %q", tokenType)))
+		}
+		if tokenType := r.PostForm.Get("requested_token_type"); tokenType != "" && tokenType != accessTokenType {
+			return whJsonErrorResponse(errors.WD(errors.ValidationFailed, fmt.Errorf("requested_token_type %q", tokenType)))
+		}
+
+		token, scopes, err = h.clientService.ExchangeToken(ctx, clientId, secret, models.TokenExchangeRequest{
+			SubjectToken: r.PostForm.Get("subject_token"),
+			Audience:     r.PostForm.Get("audience"),
+			Scope:        r.PostForm.Get("scope"),
+		})
+		issuedTokenType = accessTokenType
+	default:
+		return whJsonErrorResponse(errors.WD(errors.AuthUnsupportedGrant, fmt.Errorf("grant_type %q", grantType)))
+	}
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.TokenResponse{
+			AccessToken:     token.Token,
+			TokenType:       bearerTokenType,
+			ExpiresIn:       (token.ExpiresAt - h.timeAdapter.Now().UnixNano()/1e+6) / 1e+3,
+			Scope:           strings.Join(scopes, " "),
+			IssuedTokenType: issuedTokenType,
+		},
+		http.StatusOK,
+		nil,
+	)
+}
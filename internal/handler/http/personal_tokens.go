@@ -0,0 +1,90 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/converters"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/gorilla/mux"
+)
+
+// createPersonalTokenHandler новый персональный токен. Нужен обычный вход: токеном от чужого имени
+// или другим персональным токеном создавать нельзя
+func (h *authHandler) createPersonalTokenHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+	if ctx.Value(domain.ActorCtxKey) != nil || ctx.Value(domain.PersonalTokenCtxKey) != nil {
+		return whJsonErrorResponse(errors.AuthPersonalTokenDenied)
+	}
+	scopes, _ := ctx.Value(domain.ScopesCtxKey).([]string)
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	var req models.CreatePersonalTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return whJsonErrorResponse(errors.WD(errors.InternalError, err))
+	}
+
+	pat, token, err := h.patService.Create(ctx, acc.Role, acc.Id, scopes, req)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.CreatePersonalTokenResponse{
+			PersonalToken: converters.DomainPersonalToken2PersonalToken(pat),
+			Token:         token,
+		},
+		http.StatusCreated,
+		nil,
+	)
+}
+
+func (h *authHandler) personalTokensHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	tokens, err := h.patService.List(ctx, acc.Role, acc.Id)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		models.PersonalTokensResponse{Tokens: converters.DomainPersonalTokens2PersonalTokens(tokens)},
+		http.StatusOK,
+		nil,
+	)
+}
+
+func (h *authHandler) revokePersonalTokenHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := h.patService.Revoke(ctx, acc.Role, acc.Id, mux.Vars(r)["id"]); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusNoContent,
+		nil,
+	)
+}
@@ -0,0 +1,96 @@
+package http
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/converters"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/gorilla/mux"
+)
+
+func (h *authHandler) sessionsHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+	current, ok := ctx.Value(domain.TokenNumberCtxKey).(int64)
+	if !ok {
+		current = converters.NoCurrentSession
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	sessions, err := h.jwtService.Sessions(ctx, acc.Role, acc.Id)
+	if err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	res := models.SessionsResponse{Sessions: converters.DomainSessions2Sessions(sessions, current)}
+
+	return whJsonSuccessResponse(
+		res,
+		http.StatusOK,
+		nil,
+	)
+}
+
+// revokeSessionHandler закрывает выбранную сессию, например с потерянного устройства
+func (h *authHandler) revokeSessionHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	if acc == nil {
+		return whJsonErrorResponse(errors.AuthAuthFailed)
+	}
+
+	number, e := strconv.ParseInt(mux.Vars(r)["number"], 10, 64)
+	if e != nil {
+		return whJsonErrorResponse(errors.WD(errors.ValidationFailed, e))
+	}
+
+	var cancel func()
+	ctx, cancel = context.WithTimeout(ctx, h.timeouts.RequestTimeout)
+	defer cancel()
+
+	if err := h.jwtService.DropTokens(ctx, acc.Role, acc.Id, number); err != nil {
+		return whJsonErrorResponse(err)
+	}
+
+	return whJsonSuccessResponse(
+		nil,
+		http.StatusNoContent,
+		nil,
+	)
+}
+
+// sessionMeta по ip считаются неудачные входы, поэтому он не должен зависеть от того, что прислал клиент
+func (h *authHandler) sessionMeta(r *http.Request) domain.SessionMeta {
+	return domain.SessionMeta{
+		Ip:        clientIp(r, h.cfg.TrustedProxies),
+		UserAgent: r.UserAgent(),
+	}
+}
+
+// clientIp каждый прокси дописывает в конец X-Forwarded-For адрес, с которого к нему пришли. Начало заголовка
+// подставляет клиент, поэтому адрес берется на trustedProxies позиций с конца. Если записей меньше,
+// запрос пришел в обход прокси, и верить можно только RemoteAddr
+func clientIp(r *http.Request, trustedProxies int) string {
+	if trustedProxies > 0 {
+		hops := strings.Split(r.Header.Get(IpHeader), ",")
+		if len(hops) >= trustedProxies {
+			if ip := strings.TrimSpace(hops[len(hops)-trustedProxies]); ip != "" {
+				return ip
+			}
+		}
+	}
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/service/jwt"
+
+	"github.com/gorilla/mux"
+)
+
+type (
+	wellKnownHandler struct {
+		jwtService jwt.Service
+		reqHandler WarehouseRequestHandler
+	}
+)
+
+func NewWellKnownHandler(
+	jwtSvc jwt.Service,
+	requestHandler WarehouseRequestHandler,
+) Handler {
+	return &wellKnownHandler{
+		jwtService: jwtSvc,
+		reqHandler: requestHandler,
+	}
+}
+
+func (h *wellKnownHandler) Shutdown() {
+}
+
+func (h *wellKnownHandler) FillHandlers(router *mux.Router) {
+	base := "/.well-known"
+	r := router.PathPrefix(base).Subrouter()
+	h.reqHandler.HandleJsonRequest(r, base, "/jwks.json", http.MethodGet, h.jwksHandler)
+}
+
+// jwksHandler публикует публичные ключи, чтобы другие сервисы проверяли access токены без похода в auth
+func (h *wellKnownHandler) jwksHandler(ctx context.Context, acc *domain.Account, r *http.Request) jsonResponse {
+	return whJsonSuccessResponse(
+		h.jwtService.JWKS(),
+		http.StatusOK,
+		nil,
+	)
+}
@@ -4,18 +4,23 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/warehouse/auth-service/internal/domain"
 	"github.com/warehouse/auth-service/internal/handler/converters"
+	"github.com/warehouse/auth-service/internal/handler/models"
 	"github.com/warehouse/auth-service/internal/handler/writers"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/utils/encode"
 )
 
+// tokenHashLogLength сколько символов sha256 токена попадает в лог
+const tokenHashLogLength = 12
+
 func (m *middleware) JwtAuthMiddleware(purpose domain.AuthPurpose) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			a := r.Header.Get(AuthHeader)
-			isToken := strings.HasPrefix(a, TokenStart)
+			token, isDPoP, isToken := AuthToken(r)
 			if !isToken {
 				next.ServeHTTP(w, r)
 				return
@@ -24,21 +29,156 @@ func (m *middleware) JwtAuthMiddleware(purpose domain.AuthPurpose) func(http.Han
 			ctx, cancel := context.WithTimeout(r.Context(), m.timeouts.AuthTimeout)
 			defer cancel()
 
-			acc, num, err := m.jwtService.Auth(
-				ctx, a[TokenStartInd:], purpose,
+			// токен, обмененный для другой аудитории, к маршрутам auth сервиса не пускает
+			acc, claims, err := m.jwtService.Auth(
+				ctx, token, purpose, m.audience,
 			)
+			if err == nil {
+				err = m.checkDPoP(ctx, r, token, isDPoP, claims)
+			}
 			if err != nil {
 				details := ""
 				if err.Details != nil {
 					details = err.Details.Error()
 				}
-				m.log.Zap().Error(fmt.Sprintf("auth_failed_log_jwt err=%s, token=%s", fmt.Sprintf("(reason=%s, details=%s)", err.Reason, details), a[TokenStartInd:]))
+				// сам токен в лог не пишется: по нему можно войти, а по префиксу хеша его можно найти в других логах
+				m.log.Zap().Error(fmt.Sprintf("auth_failed_log_jwt err=%s, token_hash=%s",
+					fmt.Sprintf("(reason=%s, details=%s)", err.Reason, details), encode.HashToken(token)[:tokenHashLogLength],
+				))
 				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(err))
 				return
 			}
 			ctx = context.WithValue(r.Context(), domain.AccountCtxKey, &acc)
-			ctx = context.WithValue(ctx, domain.TokenNumberCtxKey, num)
+			// у персонального токена нет сессии, нулевой номер указал бы на чужую сессию пользователя
+			if claims.PersonalTokenId == "" {
+				ctx = context.WithValue(ctx, domain.TokenNumberCtxKey, claims.Number)
+			}
+			if claims.Actor != "" {
+				ctx = context.WithValue(ctx, domain.ActorCtxKey, claims.Actor)
+			}
+			if claims.AuthTime > 0 {
+				ctx = context.WithValue(ctx, domain.AuthTimeCtxKey, claims.AuthTime)
+			}
+			if claims.PersonalTokenId != "" {
+				ctx = context.WithValue(ctx, domain.PersonalTokenCtxKey, claims.PersonalTokenId)
+			}
+			ctx = context.WithValue(ctx, domain.ScopesCtxKey, claims.Scopes)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
+
+// checkDPoP привязанный к ключу токен принимается только по схеме DPoP и с proof на этот запрос
+func (m *middleware) checkDPoP(
+	ctx context.Context, r *http.Request, token string, isDPoP bool, claims domain.TokenClaims,
+) *errors.Error {
+	if claims.Jkt == "" {
+		return nil
+	}
+	if !isDPoP {
+		return errors.AuthDPoPProofRequired
+	}
+
+	return m.jwtService.CheckDPoPBinding(ctx, claims, r.Header.Get(DPoPHeader), domain.DPoPRequest{
+		Method:      r.Method,
+		Url:         RequestUrl(r, m.publicUrl),
+		AccessToken: token,
+	})
+}
+
+// JwtRoleMiddleware пускает только аккаунты перечисленных ролей. Токены, выпущенные от чужого имени, и персональные токены
+// не проходят: скоуп персонального токена не сужал бы права роли на этих маршрутах
+func (m *middleware) JwtRoleMiddleware(purpose domain.AuthPurpose, roles ...domain.Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.JwtAuthMiddleware(purpose)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			acc, _ := r.Context().Value(domain.AccountCtxKey).(*domain.Account)
+			if acc == nil {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.AuthAuthFailed))
+				return
+			}
+			if r.Context().Value(domain.ActorCtxKey) != nil || r.Context().Value(domain.PersonalTokenCtxKey) != nil ||
+				!hasRole(acc.Role, roles) {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.PermissionDenied))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// JwtSessionMiddleware пускает только токены сессии самого пользователя. Персональный токен не проходит: своей сессии у него нет,
+// а утекший токен с любым скоупом не должен закрывать сессии пользователя, видеть и отзывать его другие персональные токены.
+// Токен от чужого имени тоже не проходит: админ в имперсонации не должен завершать настоящие сессии пользователя
+func (m *middleware) JwtSessionMiddleware(purpose domain.AuthPurpose) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.JwtAuthMiddleware(purpose)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(domain.AccountCtxKey) == nil {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.AuthAuthFailed))
+				return
+			}
+			if r.Context().Value(domain.ActorCtxKey) != nil || r.Context().Value(domain.PersonalTokenCtxKey) != nil {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.PermissionDenied))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+func hasRole(role domain.Role, roles []domain.Role) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// JwtScopeMiddleware пускает только токены, в которых есть все перечисленные скоупы
+func (m *middleware) JwtScopeMiddleware(purpose domain.AuthPurpose, scopes ...string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.JwtAuthMiddleware(purpose)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(domain.AccountCtxKey) == nil {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.AuthAuthFailed))
+				return
+			}
+
+			granted, _ := r.Context().Value(domain.ScopesCtxKey).([]string)
+			for _, scope := range scopes {
+				if !domain.HasScope(granted, scope) {
+					writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(
+						errors.WD(errors.AuthInsufficientScope, fmt.Errorf("scope %q required", scope)),
+					))
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
+
+// JwtRecentAuthMiddleware step-up для чувствительных действий: пускает только если интерактивный вход был не раньше maxAge назад.
+// Токены без auth_time (сервисные, персональные, от чужого имени) не проходят
+func (m *middleware) JwtRecentAuthMiddleware(purpose domain.AuthPurpose, maxAge time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return m.JwtAuthMiddleware(purpose)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Context().Value(domain.AccountCtxKey) == nil {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(errors.AuthAuthFailed))
+				return
+			}
+
+			authTime, _ := r.Context().Value(domain.AuthTimeCtxKey).(int64)
+			if authTime == 0 || m.timeAdapter.Now().Sub(time.Unix(authTime, 0)) > maxAge {
+				writers.SendJSON(w, 200, converters.MakeJsonErrorResponseWithErrorsError(
+					errors.WP(errors.AuthReauthRequired, models.ReauthPayload{MaxAge: int64(maxAge / time.Second)}),
+				))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		}))
+	}
+}
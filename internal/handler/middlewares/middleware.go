@@ -2,10 +2,14 @@ package middlewares
 
 import (
 	"net/http"
+	"strings"
+	"time"
 
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
 	"github.com/warehouse/auth-service/internal/config"
 	"github.com/warehouse/auth-service/internal/domain"
 	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/service/client"
 	"github.com/warehouse/auth-service/internal/service/jwt"
 )
 
@@ -13,9 +17,14 @@ const (
 	VersionDelimiter = ":" // Разделитель составных частей версий
 	VersionHeader    = "Coffee-Version"
 
-	AuthHeader    = "Authorization"
-	TokenStart    = "Bearer "       // Префикс значения заголовка с авторизацией
-	TokenStartInd = len(TokenStart) // Индекс, с которого в заголовке авторизации должен начинаться jwt токен
+	AuthHeader          = "Authorization"
+	ServiceSecretHeader = "X-Service-Secret"
+	TokenStart          = "Bearer "       // Префикс значения заголовка с авторизацией
+	TokenStartInd       = len(TokenStart) // Индекс, с которого в заголовке авторизации должен начинаться jwt токен
+	DPoPTokenStart      = "DPoP "         // Префикс для токенов, привязанных к DPoP ключу (RFC 9449)
+	DPoPHeader          = "DPoP"
+
+	ForwardedProtoHeader = "X-Forwarded-Proto"
 
 	AccessTokenCookie  = "access_token"
 	RefreshTokenCookie = "refresh_token"
@@ -24,27 +33,75 @@ const (
 type (
 	Middleware interface {
 		JwtAuthMiddleware(purpose domain.AuthPurpose) func(http.Handler) http.Handler
+		JwtRoleMiddleware(purpose domain.AuthPurpose, roles ...domain.Role) func(http.Handler) http.Handler
+		JwtScopeMiddleware(purpose domain.AuthPurpose, scopes ...string) func(http.Handler) http.Handler
+		JwtSessionMiddleware(purpose domain.AuthPurpose) func(http.Handler) http.Handler
+		JwtRecentAuthMiddleware(purpose domain.AuthPurpose, maxAge time.Duration) func(http.Handler) http.Handler
 		QueueMiddleware(h http.Handler) http.Handler
+		ServiceAuthMiddleware(h http.Handler) http.Handler
 	}
 
 	middleware struct {
 		log logger.Logger
 
-		timeouts   config.Timeouts
-		jwtService jwt.Service
-		queue      chan struct{}
+		timeouts      config.Timeouts
+		serviceAuth   config.ServiceAuth
+		publicUrl     string
+		audience      string
+		jwtService    jwt.Service
+		clientService client.Service
+		timeAdapter   timeAdpt.Adapter
+		queue         chan struct{}
 	}
 )
 
 func NewMiddleware(
 	log logger.Logger,
 	timeouts config.Timeouts,
+	serviceAuth config.ServiceAuth,
+	publicUrl string,
+	audience string,
 	jwtService jwt.Service,
+	clientService client.Service,
+	timeAdapter timeAdpt.Adapter,
 ) Middleware {
 	return &middleware{
-		log:        log,
-		timeouts:   timeouts,
-		jwtService: jwtService,
-		queue:      make(chan struct{}, 20),
+		log:           log,
+		timeouts:      timeouts,
+		serviceAuth:   serviceAuth,
+		publicUrl:     publicUrl,
+		audience:      audience,
+		jwtService:    jwtService,
+		clientService: clientService,
+		timeAdapter:   timeAdapter,
+		queue:         make(chan struct{}, 20),
+	}
+}
+
+// AuthToken токен из заголовка Authorization со схемой Bearer или DPoP. Второе значение - схема DPoP
+func AuthToken(r *http.Request) (string, bool, bool) {
+	a := r.Header.Get(AuthHeader)
+	switch {
+	case strings.HasPrefix(a, TokenStart):
+		return a[TokenStartInd:], false, true
+	case strings.HasPrefix(a, DPoPTokenStart):
+		return a[len(DPoPTokenStart):], true, true
+	}
+	return "", false, false
+}
+
+// RequestUrl адрес запроса для сверки с htu в DPoP proof. За прокси берется publicUrl из конфига
+func RequestUrl(r *http.Request, publicUrl string) string {
+	if publicUrl != "" {
+		return strings.TrimSuffix(publicUrl, "/") + r.URL.Path
+	}
+
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	if proto := r.Header.Get(ForwardedProtoHeader); proto != "" {
+		scheme = proto
 	}
+	return scheme + "://" + r.Host + r.URL.Path
 }
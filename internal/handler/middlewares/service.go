@@ -0,0 +1,54 @@
+package middlewares
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/converters"
+	"github.com/warehouse/auth-service/internal/handler/writers"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+)
+
+const sharedSecretClientId = "shared_secret"
+
+// ServiceAuthMiddleware пропускает только внутренние сервисы: Basic client_id/secret (из конфига или
+// зарегистрированный сервисный клиент) или общий секрет в заголовке
+func (m *middleware) ServiceAuthMiddleware(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientId, ok := m.authenticateService(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="warehouse-auth"`)
+			writers.SendJSON(w, int(errors.MissingCredentials.Code), converters.MakeJsonErrorResponseWithErrorsError(errors.MissingCredentials))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), domain.ServiceClientCtxKey, clientId)
+		h.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (m *middleware) authenticateService(r *http.Request) (string, bool) {
+	if clientId, secret, ok := r.BasicAuth(); ok {
+		if expected, exists := m.serviceAuth.Clients[clientId]; exists {
+			return clientId, secretsEqual(secret, expected)
+		}
+		if _, err := m.clientService.Authenticate(r.Context(), clientId, secret); err == nil {
+			return clientId, true
+		}
+		return "", false
+	}
+
+	if secret := r.Header.Get(ServiceSecretHeader); secret != "" && m.serviceAuth.Secret != "" {
+		if secretsEqual(secret, m.serviceAuth.Secret) {
+			return sharedSecretClientId, true
+		}
+	}
+
+	return "", false
+}
+
+func secretsEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
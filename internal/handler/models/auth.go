@@ -6,6 +6,8 @@ type (
 	LoginRequestData struct {
 		Login    string `json:"login"`
 		Password string `json:"password"`
+		// Scope скоупы через пробел, чтобы сузить права сессии. Пусто - все скоупы роли
+		Scope string `json:"scope,omitempty"`
 	}
 
 	Tokens struct {
@@ -31,4 +33,77 @@ type (
 		AccId       string `json:"acc_id"`
 		NewPassword string `json:"new_password"`
 	}
+
+	PasswordChangeRequest struct {
+		Password    string `json:"password"`
+		NewPassword string `json:"new_password"`
+	}
+
+	ImpersonateRequest struct {
+		UserId string `json:"user_id"`
+		Reason string `json:"reason"`
+		// Scope скоупы через пробел. Пусто - jwt.impersonation_scopes
+		Scope string `json:"scope,omitempty"`
+	}
+
+	ImpersonateResponse struct {
+		AccessToken domain.JwtTokenInfo `json:"access_token"`
+	}
+
+	CreateClientRequest struct {
+		Name string `json:"name"`
+		// Scope скоупы клиента через пробел
+		Scope string `json:"scope"`
+	}
+
+	CreateClientResponse struct {
+		ServiceClient
+		ClientSecret string `json:"client_secret"`
+	}
+
+	ClientSecretResponse struct {
+		ClientSecret string `json:"client_secret"`
+	}
+
+	ClientsResponse struct {
+		Clients []ServiceClient `json:"clients"`
+	}
+
+	CreatePersonalTokenRequest struct {
+		Name string `json:"name"`
+		// Scope скоупы через пробел. Пусто - все скоупы текущего токена
+		Scope string `json:"scope"`
+		// ExpiresIn время жизни в секундах. 0 - бессрочный токен
+		ExpiresIn int64 `json:"expires_in"`
+	}
+
+	CreatePersonalTokenResponse struct {
+		PersonalToken
+		Token string `json:"token"`
+	}
+
+	PersonalTokensResponse struct {
+		Tokens []PersonalToken `json:"tokens"`
+	}
+
+	// ReauthPayload подсказка клиенту: войти заново, вход должен быть не старше max_age секунд
+	ReauthPayload struct {
+		MaxAge int64 `json:"max_age"`
+	}
+
+	// LockoutPayload через сколько секунд можно повторить вход
+	LockoutPayload struct {
+		RetryAfter int64 `json:"retry_after"`
+	}
+
+	// FieldError нарушение правила в поле запроса. Code стабильный, по нему клиент показывает свой текст
+	FieldError struct {
+		Field   string `json:"field"`
+		Code    string `json:"code"`
+		Message string `json:"message"`
+	}
+
+	FieldErrorsPayload struct {
+		Fields []FieldError `json:"fields"`
+	}
 )
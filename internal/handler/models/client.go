@@ -0,0 +1,10 @@
+package models
+
+type (
+	ServiceClient struct {
+		Id        string   `json:"client_id"`
+		Name      string   `json:"name"`
+		Scopes    []string `json:"scopes"`
+		CreatedAt int64    `json:"created_at"`
+	}
+)
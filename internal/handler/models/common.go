@@ -5,5 +5,6 @@ type (
 		Code    int64
 		Reason  string
 		Details string
+		Payload interface{} `json:",omitempty"`
 	}
 )
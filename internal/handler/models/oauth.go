@@ -0,0 +1,51 @@
+package models
+
+type (
+	// IntrospectionResponse ответ по RFC 7662. Для неактивного токена заполняется только active
+	IntrospectionResponse struct {
+		Active    bool     `json:"active"`
+		Sub       string   `json:"sub,omitempty"`
+		Exp       int64    `json:"exp,omitempty"`
+		Iat       int64    `json:"iat,omitempty"`
+		Nbf       int64    `json:"nbf,omitempty"`
+		Iss       string   `json:"iss,omitempty"`
+		Aud       []string `json:"aud,omitempty"`
+		Jti       string   `json:"jti,omitempty"`
+		TokenType string   `json:"token_type,omitempty"`
+		Role      *int64   `json:"role,omitempty"`
+		Number    *int64   `json:"number,omitempty"`
+		Act       *Actor   `json:"act,omitempty"`
+		Scope     string   `json:"scope,omitempty"`
+		Cnf       *Cnf     `json:"cnf,omitempty"`
+		AuthTime  int64    `json:"auth_time,omitempty"`
+		Amr       []string `json:"amr,omitempty"`
+	}
+
+	// Cnf ключ, к которому привязан токен (RFC 9449)
+	Cnf struct {
+		Jkt string `json:"jkt"`
+	}
+
+	// Actor тот, кто действует от имени владельца токена (RFC 8693)
+	Actor struct {
+		Sub string `json:"sub"`
+	}
+
+	// TokenResponse ответ токен эндпоинта по RFC 6749
+	TokenResponse struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int64  `json:"expires_in"`
+		Scope       string `json:"scope,omitempty"`
+		// IssuedTokenType тип выданного токена, только для token exchange (RFC 8693)
+		IssuedTokenType string `json:"issued_token_type,omitempty"`
+	}
+
+	// TokenExchangeRequest параметры token exchange (RFC 8693)
+	TokenExchangeRequest struct {
+		SubjectToken string
+		Audience     string
+		// Scope скоупы через пробел, подмножество скоупов subject токена. Пусто - все
+		Scope string
+	}
+)
@@ -0,0 +1,12 @@
+package models
+
+type (
+	PersonalToken struct {
+		Id         string   `json:"id"`
+		Name       string   `json:"name"`
+		Scopes     []string `json:"scopes"`
+		CreatedAt  int64    `json:"created_at"`
+		ExpiresAt  int64    `json:"expires_at,omitempty"`
+		LastUsedAt int64    `json:"last_used_at,omitempty"`
+	}
+)
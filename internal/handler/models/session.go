@@ -0,0 +1,18 @@
+package models
+
+type (
+	Session struct {
+		Number     int64    `json:"number"`
+		Ip         string   `json:"ip"`
+		UserAgent  string   `json:"user_agent"`
+		CreatedAt  int64    `json:"created_at"`
+		LastUsedAt int64    `json:"last_used_at"`
+		Current    bool     `json:"current"`
+		Scopes     []string `json:"scopes,omitempty"`
+		ActorId    string   `json:"actor_id,omitempty"`
+	}
+
+	SessionsResponse struct {
+		Sessions []Session `json:"sessions"`
+	}
+)
@@ -9,13 +9,35 @@ var (
 	AuthParseTokenRaw = errors.New("parse token failed")
 	AuthParseToken    = &Error{Code: 400, Reason: AuthParseTokenRaw.Error()}
 
-	AuthHashPassword        = &Error{Code: 400, Reason: "hashing password error"}
-	AuthExpiredToken        = &Error{Code: 400, Reason: "expired token"}
-	AuthInvalidTokenPurpose = &Error{Code: 400, Reason: "invalid token purpose"}
-	AuthInvalidToken        = &Error{Code: 400, Reason: "invalid token"}
-	AuthCreateTokens        = &Error{Code: 400, Reason: "create tokens error"}
-	AuthVerificationFailed  = &Error{Code: 400, Reason: "account was not successfully updated"}
-	AuthNotVerifiedAccount  = &Error{Code: 403, Reason: "account not verified yet"}
+	AuthHashPassword          = &Error{Code: 400, Reason: "hashing password error"}
+	AuthExpiredToken          = &Error{Code: 400, Reason: "expired token"}
+	AuthInvalidTokenPurpose   = &Error{Code: 400, Reason: "invalid token purpose"}
+	AuthInvalidToken          = &Error{Code: 400, Reason: "invalid token"}
+	AuthInvalidTokenIssuer    = &Error{Code: 401, Reason: "invalid token issuer"}
+	AuthInvalidAudience       = &Error{Code: 401, Reason: "token is not intended for this audience"}
+	AuthTokenNotValidYet      = &Error{Code: 401, Reason: "token is not valid yet"}
+	AuthRefreshTokenReused    = &Error{Code: 401, Reason: "refresh token reuse detected, session revoked"}
+	AuthCreateTokens          = &Error{Code: 400, Reason: "create tokens error"}
+	AuthVerificationFailed    = &Error{Code: 400, Reason: "account was not successfully updated"}
+	AuthNotVerifiedAccount    = &Error{Code: 403, Reason: "account not verified yet"}
+	AuthInvalidCredentials    = &Error{Code: 401, Reason: "invalid credentials"}
+	AuthPasswordNotChanged    = &Error{Code: 500, Reason: "password was not changed"}
+	AuthLoginLocked           = &Error{Code: 429, Reason: "too many failed login attempts"}
+	AuthSessionLimitReached   = &Error{Code: 409, Reason: "active sessions limit reached"}
+	AuthSessionExpired        = &Error{Code: 401, Reason: "session expired"}
+	AuthInvalidScope          = &Error{Code: 400, Reason: "requested scope is not allowed"}
+	AuthInsufficientScope     = &Error{Code: 403, Reason: "insufficient scope"}
+	AuthInvalidClient         = &Error{Code: 401, Reason: "invalid client"}
+	AuthUnsupportedGrant      = &Error{Code: 400, Reason: "unsupported grant type"}
+	AuthInvalidGrant          = &Error{Code: 400, Reason: "invalid grant"}
+	AuthInvalidTarget         = &Error{Code: 400, Reason: "invalid target audience"}
+	AuthClientNotFound        = &Error{Code: 404, Reason: "service client not found"}
+	AuthPersonalTokenDenied   = &Error{Code: 403, Reason: "personal access tokens cannot be managed with this token"}
+	AuthPersonalTokenNotFound = &Error{Code: 404, Reason: "personal access token not found"}
+	AuthInvalidDPoPProof      = &Error{Code: 401, Reason: "invalid dpop proof"}
+	AuthDPoPProofRequired     = &Error{Code: 401, Reason: "token is bound to a dpop key, dpop proof required"}
+	AuthDPoPKeyMismatch       = &Error{Code: 401, Reason: "dpop proof key does not match token binding"}
+	AuthReauthRequired        = &Error{Code: 401, Reason: "reauthentication required"}
 
 	AuthUserAlreadyExists = &Error{Code: 409, Reason: "user already exists"}
 
@@ -27,6 +49,12 @@ var (
 
 	AuthGetUserDataFailed = &Error{Code: 400, Reason: "get user data failed"}
 
-	CreateToken       = errors.New("token not created")
-	TokenDoesNotExist = errors.New("token does not exist")
+	CreateToken                   = errors.New("token not created")
+	TokenDoesNotExist             = errors.New("token does not exist")
+	SessionDoesNotExist           = errors.New("session does not exist")
+	ServiceClientDoesNotExist     = errors.New("service client does not exist")
+	PersonalTokenDoesNotExist     = errors.New("personal access token does not exist")
+	DPoPProofReplayed             = errors.New("dpop proof replayed")
+	VerificationTokenDoesNotExist = errors.New("verification token does not exist")
+	ResetTokenDoesNotExist        = errors.New("reset token does not exist")
 )
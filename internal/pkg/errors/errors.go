@@ -10,6 +10,8 @@ type Error struct {
 	Code    int64  `json:"code"`
 	Reason  string `json:"reason"`
 	Details error  `json:"details"`
+	// Payload структурированные данные для клиента, отдаются в ответе как есть
+	Payload interface{} `json:"payload,omitempty"`
 }
 
 var (
@@ -39,6 +41,13 @@ func WD(err *Error, details error) *Error {
 	return &e
 }
 
+// WP WP значит WithPayload
+func WP(err *Error, payload interface{}) *Error {
+	e := *err
+	e.Payload = payload
+	return &e
+}
+
 func DatabaseError(details error) *Error {
 	return WD(&Error{Code: 500, Reason: "database failed"}, details)
 }
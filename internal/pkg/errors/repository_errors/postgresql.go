@@ -15,4 +15,7 @@ var (
 	PostgresqlNoRowsWereAffected = errors.New("no rows were affected")
 
 	PgTx = errors.New("postgresql transaction error") // PostgresqlTransaction
+
+	MemoryGetRaw  = errors.New("memory storage get error")
+	MemoryExecRaw = errors.New("memory storage exec error")
 )
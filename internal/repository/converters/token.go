@@ -1,6 +1,8 @@
 package rep_converters
 
 import (
+	"strings"
+
 	"github.com/warehouse/auth-service/internal/domain"
 	wh_converters "github.com/warehouse/auth-service/internal/pkg/utils/converters"
 	"github.com/warehouse/auth-service/internal/repository/models"
@@ -45,3 +47,57 @@ func ModelResetToken2DomainResetToken(t models.ResetToken) domain.ResetTokenInfo
 		CreatedAt: t.CreatedAt,
 	}
 }
+
+func DomainSession2ModelSession(s domain.Session) models.Session {
+	return models.Session{
+		Role:       int64(s.Role),
+		UserId:     s.UserId,
+		Number:     s.Number,
+		Ip:         s.Ip,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		Scope:      strings.Join(s.Scopes, " "),
+		Jkt:        s.Jkt,
+		AuthTime:   s.AuthTime,
+		Amr:        strings.Join(s.Amr, " "),
+		ActorId:    s.ActorId,
+	}
+}
+
+func ModelSession2DomainSession(s models.Session) domain.Session {
+	return domain.Session{
+		Role:       domain.Role(s.Role),
+		UserId:     s.UserId,
+		Number:     s.Number,
+		Ip:         s.Ip,
+		UserAgent:  s.UserAgent,
+		CreatedAt:  s.CreatedAt,
+		LastUsedAt: s.LastUsedAt,
+		Scopes:     strings.Fields(s.Scope),
+		Jkt:        s.Jkt,
+		AuthTime:   s.AuthTime,
+		Amr:        strings.Fields(s.Amr),
+		ActorId:    s.ActorId,
+	}
+}
+
+func ModelServiceClient2DomainServiceClient(c models.ServiceClient) domain.ServiceClient {
+	return domain.ServiceClient{
+		Id:        c.Id,
+		Name:      c.Name,
+		Scopes:    strings.Fields(c.Scope),
+		CreatedAt: c.CreatedAt,
+	}
+}
+
+func ModelPersonalToken2DomainPersonalToken(token models.PersonalToken) domain.PersonalToken {
+	return domain.PersonalToken{
+		Id:         token.Id,
+		Name:       token.Name,
+		Scopes:     strings.Fields(token.Scope),
+		CreatedAt:  token.CreatedAt,
+		ExpiresAt:  token.ExpiresAt,
+		LastUsedAt: token.LastUsedAt,
+	}
+}
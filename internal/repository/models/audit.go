@@ -0,0 +1,16 @@
+package models
+
+type (
+	// ImpersonationAudit запись о выпуске токена админом от имени пользователя. Время в миллисекундах
+	ImpersonationAudit struct {
+		AdminId      string `db:"admin_id"`
+		TargetUserId string `db:"target_user_id"`
+		TargetRole   int64  `db:"target_role"`
+		Number       int64  `db:"number"`
+		Reason       string `db:"reason"`
+		Ip           string `db:"ip"`
+		UserAgent    string `db:"user_agent"`
+		CreatedAt    int64  `db:"created_at"`
+		ExpiresAt    int64  `db:"expires_at"`
+	}
+)
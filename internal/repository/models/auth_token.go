@@ -2,10 +2,12 @@ package models
 
 type (
 	Token struct {
+		Role      int64  `db:"role"`
 		UserId    string `db:"user_id"`
 		Number    int64  `db:"number"`
 		Purpose   int    `db:"purpose"`
 		Secret    string `db:"secret"`
 		ExpiresAt int64  `db:"expires_at"`
+		FamilyId  string `db:"family_id"`
 	}
 )
@@ -0,0 +1,12 @@
+package models
+
+type (
+	// LoginFailure счетчик неудачных входов по логину или ip. Время в миллисекундах
+	LoginFailure struct {
+		Scope       string `db:"scope"`
+		Key         string `db:"key"`
+		Failures    int64  `db:"failures"`
+		LockedUntil int64  `db:"locked_until"`
+		ExpiresAt   int64  `db:"expires_at"`
+	}
+)
@@ -0,0 +1,15 @@
+package models
+
+type (
+	PersonalToken struct {
+		Id         string `db:"id"`
+		Role       int64  `db:"role"`
+		UserId     string `db:"user_id"`
+		Name       string `db:"name"`
+		TokenHash  string `db:"token_hash"`
+		Scope      string `db:"scope"`
+		CreatedAt  int64  `db:"created_at"`
+		ExpiresAt  int64  `db:"expires_at"`
+		LastUsedAt int64  `db:"last_used_at"`
+	}
+)
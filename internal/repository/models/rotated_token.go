@@ -0,0 +1,14 @@
+package models
+
+type (
+	// RotatedToken refresh токен, который уже обменяли на новую пару. Повторное предъявление - признак кражи
+	RotatedToken struct {
+		Secret    string `db:"secret"`
+		FamilyId  string `db:"family_id"`
+		Role      int64  `db:"role"`
+		UserId    string `db:"user_id"`
+		Number    int64  `db:"number"`
+		RotatedAt int64  `db:"rotated_at"`
+		ExpiresAt int64  `db:"expires_at"`
+	}
+)
@@ -0,0 +1,11 @@
+package models
+
+type (
+	ServiceClient struct {
+		Id         string `db:"id"`
+		Name       string `db:"name"`
+		SecretHash string `db:"secret_hash"`
+		Scope      string `db:"scope"`
+		CreatedAt  int64  `db:"created_at"`
+	}
+)
@@ -0,0 +1,18 @@
+package models
+
+type (
+	Session struct {
+		Role       int64  `db:"role"`
+		UserId     string `db:"user_id"`
+		Number     int64  `db:"number"`
+		Ip         string `db:"ip"`
+		UserAgent  string `db:"user_agent"`
+		CreatedAt  int64  `db:"created_at"`
+		LastUsedAt int64  `db:"last_used_at"`
+		Scope      string `db:"scope"`
+		Jkt        string `db:"jkt"`
+		AuthTime   int64  `db:"auth_time"`
+		Amr        string `db:"amr"`
+		ActorId    string `db:"actor_id"`
+	}
+)
@@ -0,0 +1,12 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	AddImpersonationTX(ctx context.Context, tx transactions.Transaction, record models.ImpersonationAudit) error
+}
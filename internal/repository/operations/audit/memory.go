@@ -0,0 +1,30 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log     logger.Logger
+	records *db.MemoryTable[models.ImpersonationAudit]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:     log.Named("memory_audit"),
+		records: db.MemoryTableOf[models.ImpersonationAudit](client, "impersonation_audit"),
+	}
+}
+
+func (r *repositoryMemory) AddImpersonationTX(ctx context.Context, tx transactions.Transaction, record models.ImpersonationAudit) error {
+	if err := r.records.Insert(transactions.Memory(tx), record); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert impersonation_audit")
+	}
+	return nil
+}
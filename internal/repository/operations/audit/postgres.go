@@ -0,0 +1,36 @@
+package audit
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_audit"),
+	}
+}
+
+func (r *repositoryPG) AddImpersonationTX(ctx context.Context, tx transactions.Transaction, record models.ImpersonationAudit) error {
+	query := `
+		INSERT INTO impersonation_audit
+			(admin_id, target_user_id, target_role, number, reason, ip, user_agent, created_at, expires_at)
+		VALUES(:admin_id, :target_user_id, :target_role, :number, :reason, :ip, :user_agent, :created_at, :expires_at)
+	`
+	if _, err := tx.Txm().NamedExecContext(ctx, query, record); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
@@ -0,0 +1,12 @@
+package dpop
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	AddProofTX(ctx context.Context, tx transactions.Transaction, jkt, jti string, expiresAt int64) error
+	DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
+}
@@ -0,0 +1,62 @@
+package dpop
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type (
+	proof struct {
+		jkt       string
+		jti       string
+		expiresAt int64
+	}
+
+	repositoryMemory struct {
+		log    logger.Logger
+		proofs *db.MemoryTable[proof]
+	}
+)
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:    log.Named("memory_dpop_proofs"),
+		proofs: db.MemoryTableOf[proof](client, "dpop_proofs"),
+	}
+}
+
+func (r *repositoryMemory) AddProofTX(ctx context.Context, tx transactions.Transaction, jkt, jti string, expiresAt int64) error {
+	memTx := transactions.Memory(tx)
+	seen, err := r.proofs.Select(memTx, func(p proof) bool {
+		return p.jkt == jkt && p.jti == jti
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select dpop_proofs")
+	}
+
+	if len(seen) > 0 {
+		return errors.DPoPProofReplayed
+	}
+
+	if err = r.proofs.Insert(memTx, proof{jkt: jkt, jti: jti, expiresAt: expiresAt}); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert dpop_proofs")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.proofs.Delete(transactions.Memory(tx), func(p proof) bool {
+		return p.expiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete dpop_proofs")
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,63 @@
+package dpop
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_dpop"),
+	}
+}
+
+// AddProofTX запоминает jti proof. Повторный jti для того же ключа - errors.DPoPProofReplayed
+func (r *repositoryPG) AddProofTX(ctx context.Context, tx transactions.Transaction, jkt, jti string, expiresAt int64) error {
+	query := `
+		INSERT INTO dpop_proofs (jkt, jti, expires_at) VALUES($1, $2, $3)
+		ON CONFLICT (jkt, jti) DO NOTHING
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, jkt, jti, expiresAt)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+	if rowsAffected == 0 {
+		return errors.DPoPProofReplayed
+	}
+
+	return nil
+}
+
+// DropExpiredTX удаляет не больше limit протухших jti, timestamp в миллисекундах
+func (r *repositoryPG) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	query := `
+		DELETE FROM dpop_proofs WHERE ctid IN (SELECT ctid FROM dpop_proofs WHERE expires_at<=$1 LIMIT $2)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
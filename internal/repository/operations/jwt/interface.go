@@ -9,12 +9,17 @@ import (
 )
 
 type Repository interface {
+	LockUserTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error
 	DropAllTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error
 	DropTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64) error
 	FindNumberTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) (int64, error)
 	AddTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error)
 	CheckTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error)
-	DropOldTokens(ctx context.Context, tx transactions.Transaction, timestamp int64) error
+	GetTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, purpose domain.AuthPurpose) (models.Token, error)
+	DropFamilyTX(ctx context.Context, tx transactions.Transaction, role domain.Role, familyId string) error
 
-	GetTokenMap() map[domain.Role]string
+	AddRotatedTokenTX(ctx context.Context, tx transactions.Transaction, token models.RotatedToken) error
+	GetRotatedTokenTX(ctx context.Context, tx transactions.Transaction, secret string) (models.RotatedToken, error)
+	DropExpiredRotatedTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
+	DropExpiredTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
 }
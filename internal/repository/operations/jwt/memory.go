@@ -0,0 +1,175 @@
+package jwt
+
+import (
+	"context"
+	"slices"
+	"sort"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log     logger.Logger
+	tokens  *db.MemoryTable[models.Token]
+	rotated *db.MemoryTable[models.RotatedToken]
+}
+
+func NewMemoryRepository(
+	log logger.Logger,
+	client *db.MemoryClient,
+) Repository {
+	return &repositoryMemory{
+		log:     log.Named("memory_jwt_repo"),
+		tokens:  db.MemoryTableOf[models.Token](client, "tokens"),
+		rotated: db.MemoryTableOf[models.RotatedToken](client, "rotated_refresh_tokens"),
+	}
+}
+
+// LockUserTX пишущие транзакции в памяти и так идут по одной
+func (r *repositoryMemory) LockUserTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
+	return nil
+}
+
+// FindNumberTX как и в postgres, номер занят, пока жив любой токен сессии
+func (r *repositoryMemory) FindNumberTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) (int64, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == userId
+	})
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select tokens")
+	}
+
+	numbers := make([]int64, 0, len(tokens))
+	for _, t := range tokens {
+		numbers = append(numbers, t.Number)
+	}
+	sort.Slice(numbers, func(i, j int) bool { return numbers[i] < numbers[j] })
+	numbers = slices.Compact(numbers)
+
+	return findNumbers(numbers)
+}
+
+func (r *repositoryMemory) AddTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error) {
+	token.Role = int64(role)
+	if err := r.tokens.Insert(transactions.Memory(tx), token); err != nil {
+		return models.Token{}, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert tokens")
+	}
+
+	return token, nil
+}
+
+func (r *repositoryMemory) DropTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == userId && t.Number == number
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DropAllTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == userId
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete tokens")
+	}
+	return nil
+}
+
+func (r *repositoryMemory) CheckTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == token.UserId && t.Number == token.Number &&
+			t.Purpose == token.Purpose && t.Secret == token.Secret
+	})
+	if err != nil {
+		return models.Token{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.Token{}, errors.TokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) GetTokenTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, purpose domain.AuthPurpose,
+) (models.Token, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == userId && t.Number == number && t.Purpose == int(purpose)
+	})
+	if err != nil {
+		return models.Token{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.Token{}, errors.TokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) AddRotatedTokenTX(ctx context.Context, tx transactions.Transaction, token models.RotatedToken) error {
+	if err := r.rotated.Insert(transactions.Memory(tx), token); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert rotated_refresh_tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) GetRotatedTokenTX(ctx context.Context, tx transactions.Transaction, secret string) (models.RotatedToken, error) {
+	tokens, err := r.rotated.Select(transactions.Memory(tx), func(t models.RotatedToken) bool {
+		return t.Secret == secret
+	})
+	if err != nil {
+		return models.RotatedToken{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select rotated_refresh_tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.RotatedToken{}, errors.TokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) DropFamilyTX(ctx context.Context, tx transactions.Transaction, role domain.Role, familyId string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.Token) bool {
+		return t.Role == int64(role) && t.FamilyId == familyId
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DropExpiredTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.Token) bool {
+		return t.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete tokens")
+	}
+
+	return removed, nil
+}
+
+func (r *repositoryMemory) DropExpiredRotatedTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.rotated.Delete(transactions.Memory(tx), func(t models.RotatedToken) bool {
+		return t.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete rotated_refresh_tokens")
+	}
+
+	return removed, nil
+}
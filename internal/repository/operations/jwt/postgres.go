@@ -3,7 +3,6 @@ package jwt
 import (
 	"context"
 	"database/sql"
-	"fmt"
 
 	"github.com/warehouse/auth-service/internal/db"
 	"github.com/warehouse/auth-service/internal/domain"
@@ -17,9 +16,8 @@ import (
 )
 
 type repositoryPG struct {
-	log      logger.Logger
-	pg       *db.PostgresClient
-	tokenMap map[domain.Role]string
+	log logger.Logger
+	pg  *db.PostgresClient
 }
 
 func NewPGRepository(
@@ -27,22 +25,31 @@ func NewPGRepository(
 	client *db.PostgresClient,
 ) Repository {
 	return &repositoryPG{
-		log:      log.Named("pg_jwt_repo"),
-		pg:       client,
-		tokenMap: UserTokenMap,
+		log: log.Named("pg_jwt_repo"),
+		pg:  client,
 	}
 }
 
+// LockUserTX берет advisory lock на пользователя до конца транзакции,
+// чтобы параллельные логины (в том числе с разных реплик) не получили один номер сессии
+func (r *repositoryPG) LockUserTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
+	query := `SELECT pg_advisory_xact_lock($1, hashtext($2))`
+	if _, err := tx.Txm().ExecContext(ctx, query, int32(role), userId); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+	return nil
+}
+
+// FindNumberTX номер занят, пока жив любой токен сессии: access строку чистка удаляет раньше refresh
 func (r *repositoryPG) FindNumberTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) (int64, error) {
 	var numbers []int64
-	queryString := `
-		SELECT  number 
-		FROM %s 
-		WHERE user_id=$1 AND purpose=0 
+	query := `
+		SELECT DISTINCT number
+		FROM tokens
+		WHERE role=$1 AND user_id=$2
 		ORDER BY number
 	`
-	query := fmt.Sprintf(queryString, r.tokenMap[role])
-	err := tx.Txm().SelectContext(ctx, &numbers, query, userId)
+	err := tx.Txm().SelectContext(ctx, &numbers, query, role, userId)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			numbers = []int64{}
@@ -50,15 +57,15 @@ func (r *repositoryPG) FindNumberTX(ctx context.Context, tx transactions.Transac
 		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlQueryRowRaw, query)
 	}
 
-	return r.findNumbers(numbers)
+	return findNumbers(numbers)
 }
 
 func (r *repositoryPG) AddTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error) {
-	queryString := `
-		INSERT INTO %s (user_id, number, purpose, secret, expires_at)
-		VALUES(:user_id, :number, :purpose, :secret, :expires_at)
+	query := `
+		INSERT INTO tokens (role, user_id, number, purpose, secret, expires_at, family_id)
+		VALUES(:role, :user_id, :number, :purpose, :secret, :expires_at, :family_id)
 	`
-	query := fmt.Sprintf(queryString, r.tokenMap[role])
+	token.Role = int64(role)
 
 	res, err := tx.Txm().NamedExecContext(ctx, query, token)
 	if err != nil {
@@ -78,9 +85,8 @@ func (r *repositoryPG) AddTokenTX(ctx context.Context, tx transactions.Transacti
 }
 
 func (r *repositoryPG) DropTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64) error {
-	queryString := `DELETE FROM %s WHERE user_id=$1 AND number=$2`
-	query := fmt.Sprintf(queryString, r.tokenMap[role])
-	_, err := tx.Txm().ExecContext(ctx, query, userId, number)
+	query := `DELETE FROM tokens WHERE role=$1 AND user_id=$2 AND number=$3`
+	_, err := tx.Txm().ExecContext(ctx, query, role, userId, number)
 	if err != nil {
 		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
 	}
@@ -89,9 +95,8 @@ func (r *repositoryPG) DropTokensTX(ctx context.Context, tx transactions.Transac
 }
 
 func (r *repositoryPG) DropAllTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
-	queryString := `DELETE FROM %s WHERE user_id=$1`
-	query := fmt.Sprintf(queryString, r.tokenMap[role])
-	_, err := tx.Txm().ExecContext(ctx, query, userId)
+	query := `DELETE FROM tokens WHERE role=$1 AND user_id=$2`
+	_, err := tx.Txm().ExecContext(ctx, query, role, userId)
 	if err != nil {
 		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
 	}
@@ -99,20 +104,22 @@ func (r *repositoryPG) DropAllTokensTX(ctx context.Context, tx transactions.Tran
 }
 
 func (r *repositoryPG) CheckTokenTX(ctx context.Context, tx transactions.Transaction, role domain.Role, token models.Token) (models.Token, error) {
-	queryString := `
-		SELECT id, number, purpose, secret, expires_at
-		FROM %s
-		WHERE user_id=:user_id
+	query := `
+		SELECT role, user_id, number, purpose, secret, expires_at, family_id
+		FROM tokens
+		WHERE role=:role
+			AND user_id=:user_id
 			AND number=:number
 			AND purpose=:purpose
 			AND secret=:secret
 	`
-	query := fmt.Sprintf(queryString, r.tokenMap[role])
+	token.Role = int64(role)
 
 	rows, err := sqlx.NamedQueryContext(ctx, tx.Txm(), query, token)
 	if err != nil {
 		return models.Token{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
 	}
+	defer rows.Close()
 
 	if !rows.Next() {
 		return models.Token{}, errors.TokenDoesNotExist
@@ -126,25 +133,106 @@ func (r *repositoryPG) CheckTokenTX(ctx context.Context, tx transactions.Transac
 	return token, nil
 }
 
-func (r *repositoryPG) DropOldTokens(ctx context.Context, tx transactions.Transaction, timestamp int64) error {
-	for _, t := range r.tokenMap {
-		query := `
-			DELETE FROM %s WHERE number IN (SELECT number FROM %s WHERE purpose=$1 AND expires_at<=$2)
-		`
-		_, err := tx.Txm().ExecContext(ctx, fmt.Sprintf(query, t, t), 1, timestamp)
-		if err != nil {
-			return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
-		}
+func (r *repositoryPG) GetTokenTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, purpose domain.AuthPurpose,
+) (models.Token, error) {
+	query := `
+		SELECT role, user_id, number, purpose, secret, expires_at, family_id
+		FROM tokens
+		WHERE role=$1 AND user_id=$2 AND number=$3 AND purpose=$4
+	`
+
+	var tokens []models.Token
+	if err := tx.Txm().SelectContext(ctx, &tokens, query, role, userId, number, purpose); err != nil {
+		return models.Token{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	if len(tokens) == 0 {
+		return models.Token{}, errors.TokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryPG) AddRotatedTokenTX(ctx context.Context, tx transactions.Transaction, token models.RotatedToken) error {
+	query := `
+		INSERT INTO rotated_refresh_tokens (secret, family_id, role, user_id, number, rotated_at, expires_at)
+		VALUES(:secret, :family_id, :role, :user_id, :number, :rotated_at, :expires_at)
+	`
+	if _, err := tx.Txm().NamedExecContext(ctx, query, token); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) GetRotatedTokenTX(ctx context.Context, tx transactions.Transaction, secret string) (models.RotatedToken, error) {
+	query := `
+		SELECT secret, family_id, role, user_id, number, rotated_at, expires_at
+		FROM rotated_refresh_tokens
+		WHERE secret=$1
+	`
+
+	var tokens []models.RotatedToken
+	if err := tx.Txm().SelectContext(ctx, &tokens, query, secret); err != nil {
+		return models.RotatedToken{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
 	}
+
+	if len(tokens) == 0 {
+		return models.RotatedToken{}, errors.TokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryPG) DropFamilyTX(ctx context.Context, tx transactions.Transaction, role domain.Role, familyId string) error {
+	query := `DELETE FROM tokens WHERE role=$1 AND family_id=$2`
+	if _, err := tx.Txm().ExecContext(ctx, query, role, familyId); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
 	return nil
 }
 
-func (r *repositoryPG) GetTokenMap() map[domain.Role]string {
-	return r.tokenMap
+// DropExpiredTokensTX удаляет не больше limit протухших токенов, timestamp в миллисекундах
+func (r *repositoryPG) DropExpiredTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	query := `
+		DELETE FROM tokens WHERE ctid IN (SELECT ctid FROM tokens WHERE expires_at<=$1 LIMIT $2)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
 }
 
-func (r *repositoryPG) findNumbers(numbers []int64) (int64, error) {
+// DropExpiredRotatedTokensTX история ротации нужна, только пока обменянный refresh токен еще не протух
+func (r *repositoryPG) DropExpiredRotatedTokensTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	query := `
+		DELETE FROM rotated_refresh_tokens
+		WHERE secret IN (SELECT secret FROM rotated_refresh_tokens WHERE expires_at<=$1 LIMIT $2)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
 
+// findNumbers первый свободный номер сессии, numbers отсортированы
+func findNumbers(numbers []int64) (int64, error) {
 	if len(numbers) == 0 {
 		return 0, nil
 	}
@@ -0,0 +1,18 @@
+package login_failure
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	// AddFailureTX увеличивает счетчик, протухший счетчик начинается с единицы. Возвращает счетчик после увеличения
+	AddFailureTX(ctx context.Context, tx transactions.Transaction, scope, key string, timestamp, expiresAt int64) (models.LoginFailure, error)
+	// ForgiveTX снимает одну попытку. Если счетчик опустился ниже threshold, снимается и блокировка
+	ForgiveTX(ctx context.Context, tx transactions.Transaction, scope, key string, threshold int64) error
+	LockTX(ctx context.Context, tx transactions.Transaction, scope, key string, lockedUntil int64) error
+	DeleteTX(ctx context.Context, tx transactions.Transaction, scope, key string) error
+	DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
+}
@@ -0,0 +1,103 @@
+package login_failure
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log      logger.Logger
+	failures *db.MemoryTable[models.LoginFailure]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:      log.Named("memory_login_failures"),
+		failures: db.MemoryTableOf[models.LoginFailure](client, "login_failures"),
+	}
+}
+
+func (r *repositoryMemory) AddFailureTX(
+	ctx context.Context, tx transactions.Transaction, scope, key string, timestamp, expiresAt int64,
+) (models.LoginFailure, error) {
+	memTx := transactions.Memory(tx)
+	failure := models.LoginFailure{Scope: scope, Key: key, Failures: 1, ExpiresAt: expiresAt}
+	updated, err := r.failures.Update(memTx, func(f models.LoginFailure) bool {
+		return f.Scope == scope && f.Key == key
+	}, func(f *models.LoginFailure) {
+		if f.ExpiresAt <= timestamp {
+			f.Failures, f.LockedUntil = 0, 0
+		}
+		f.Failures++
+		f.ExpiresAt = max(expiresAt, f.LockedUntil)
+		failure = *f
+	})
+	if err != nil {
+		return models.LoginFailure{}, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update login_failures")
+	}
+
+	if updated == 0 {
+		if err = r.failures.Insert(memTx, failure); err != nil {
+			return models.LoginFailure{}, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert login_failures")
+		}
+	}
+
+	return failure, nil
+}
+
+func (r *repositoryMemory) ForgiveTX(ctx context.Context, tx transactions.Transaction, scope, key string, threshold int64) error {
+	_, err := r.failures.Update(transactions.Memory(tx), func(f models.LoginFailure) bool {
+		return f.Scope == scope && f.Key == key
+	}, func(f *models.LoginFailure) {
+		f.Failures = max(f.Failures-1, 0)
+		if f.Failures < threshold {
+			f.LockedUntil = 0
+		}
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update login_failures")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) LockTX(ctx context.Context, tx transactions.Transaction, scope, key string, lockedUntil int64) error {
+	_, err := r.failures.Update(transactions.Memory(tx), func(f models.LoginFailure) bool {
+		return f.Scope == scope && f.Key == key
+	}, func(f *models.LoginFailure) {
+		f.LockedUntil = lockedUntil
+		f.ExpiresAt = max(f.ExpiresAt, lockedUntil)
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update login_failures")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteTX(ctx context.Context, tx transactions.Transaction, scope, key string) error {
+	_, err := r.failures.Delete(transactions.Memory(tx), func(f models.LoginFailure) bool {
+		return f.Scope == scope && f.Key == key
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete login_failures")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.failures.Delete(transactions.Memory(tx), func(f models.LoginFailure) bool {
+		return f.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete login_failures")
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,100 @@
+package login_failure
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_login_failures"),
+	}
+}
+
+// AddFailureTX увеличение идет одним запросом, поэтому параллельные неудачные входы не теряются
+func (r *repositoryPG) AddFailureTX(
+	ctx context.Context, tx transactions.Transaction, scope, key string, timestamp, expiresAt int64,
+) (models.LoginFailure, error) {
+	query := `
+		INSERT INTO login_failures (scope, key, failures, locked_until, expires_at)
+		VALUES($1, $2, 1, 0, $4)
+		ON CONFLICT (scope, key) DO UPDATE SET
+			failures=CASE WHEN login_failures.expires_at<=$3 THEN 1 ELSE login_failures.failures+1 END,
+			locked_until=CASE WHEN login_failures.expires_at<=$3 THEN 0 ELSE login_failures.locked_until END,
+			expires_at=CASE WHEN login_failures.expires_at<=$3 THEN EXCLUDED.expires_at
+				ELSE GREATEST(EXCLUDED.expires_at, login_failures.locked_until) END
+		RETURNING scope, key, failures, locked_until, expires_at
+	`
+
+	var failure models.LoginFailure
+	if err := tx.Txm().GetContext(ctx, &failure, query, scope, key, timestamp, expiresAt); err != nil {
+		return models.LoginFailure{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return failure, nil
+}
+
+func (r *repositoryPG) ForgiveTX(ctx context.Context, tx transactions.Transaction, scope, key string, threshold int64) error {
+	query := `
+		UPDATE login_failures SET
+			failures=GREATEST(failures-1, 0),
+			locked_until=CASE WHEN failures-1<$3 THEN 0 ELSE locked_until END
+		WHERE scope=$1 AND key=$2
+	`
+	if _, err := tx.Txm().ExecContext(ctx, query, scope, key, threshold); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// LockTX счетчик живет не меньше блокировки, иначе его сбросит раньше, чем она кончится
+func (r *repositoryPG) LockTX(ctx context.Context, tx transactions.Transaction, scope, key string, lockedUntil int64) error {
+	query := `
+		UPDATE login_failures SET locked_until=$3, expires_at=GREATEST(expires_at, $3)
+		WHERE scope=$1 AND key=$2
+	`
+	if _, err := tx.Txm().ExecContext(ctx, query, scope, key, lockedUntil); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) DeleteTX(ctx context.Context, tx transactions.Transaction, scope, key string) error {
+	query := `DELETE FROM login_failures WHERE scope=$1 AND key=$2`
+	if _, err := tx.Txm().ExecContext(ctx, query, scope, key); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// DropExpiredTX удаляет не больше limit протухших счетчиков, timestamp в миллисекундах
+func (r *repositoryPG) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	query := `
+		DELETE FROM login_failures WHERE ctid IN (SELECT ctid FROM login_failures WHERE expires_at<=$1 LIMIT $2)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,20 @@
+package personal_token
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	CreateTX(ctx context.Context, tx transactions.Transaction, token models.PersonalToken) error
+	GetByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) (models.PersonalToken, error)
+	ListTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) ([]models.PersonalToken, error)
+	TouchTX(ctx context.Context, tx transactions.Transaction, id string, timestamp int64) error
+	DeleteByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) error
+	DeleteTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId, id string) error
+	DeleteAllTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error
+	DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
+}
@@ -0,0 +1,122 @@
+package personal_token
+
+import (
+	"context"
+	"sort"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log    logger.Logger
+	tokens *db.MemoryTable[models.PersonalToken]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:    log.Named("memory_personal_tokens"),
+		tokens: db.MemoryTableOf[models.PersonalToken](client, "personal_access_tokens"),
+	}
+}
+
+func (r *repositoryMemory) CreateTX(ctx context.Context, tx transactions.Transaction, token models.PersonalToken) error {
+	if err := r.tokens.Insert(transactions.Memory(tx), token); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert personal_access_tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) GetByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) (models.PersonalToken, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.TokenHash == tokenHash
+	})
+	if err != nil {
+		return models.PersonalToken{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select personal_access_tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.PersonalToken{}, errors.PersonalTokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) ListTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) ([]models.PersonalToken, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.Role == int64(role) && t.UserId == userId
+	})
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select personal_access_tokens")
+	}
+
+	sort.SliceStable(tokens, func(i, j int) bool { return tokens[i].CreatedAt < tokens[j].CreatedAt })
+	return tokens, nil
+}
+
+func (r *repositoryMemory) TouchTX(ctx context.Context, tx transactions.Transaction, id string, timestamp int64) error {
+	_, err := r.tokens.Update(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.Id == id
+	}, func(t *models.PersonalToken) {
+		t.LastUsedAt = timestamp
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update personal_access_tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.TokenHash == tokenHash
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete personal_access_tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId, id string) error {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.Role == int64(role) && t.UserId == userId && t.Id == id
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete personal_access_tokens")
+	}
+
+	if removed == 0 {
+		return errors.PersonalTokenDoesNotExist
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteAllTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.Role == int64(role) && t.UserId == userId
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete personal_access_tokens")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.PersonalToken) bool {
+		return t.ExpiresAt > 0 && t.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete personal_access_tokens")
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,132 @@
+package personal_token
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+const columns = `id, role, user_id, name, token_hash, scope, created_at, expires_at, last_used_at`
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_personal_tokens"),
+	}
+}
+
+func (r *repositoryPG) CreateTX(ctx context.Context, tx transactions.Transaction, token models.PersonalToken) error {
+	query := `
+		INSERT INTO personal_access_tokens (` + columns + `)
+		VALUES(:id, :role, :user_id, :name, :token_hash, :scope, :created_at, :expires_at, :last_used_at)
+	`
+	if _, err := tx.Txm().NamedExecContext(ctx, query, token); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) GetByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) (models.PersonalToken, error) {
+	query := `SELECT ` + columns + ` FROM personal_access_tokens WHERE token_hash=$1`
+
+	var tokens []models.PersonalToken
+	if err := tx.Txm().SelectContext(ctx, &tokens, query, tokenHash); err != nil {
+		return models.PersonalToken{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	if len(tokens) == 0 {
+		return models.PersonalToken{}, errors.PersonalTokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryPG) ListTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) ([]models.PersonalToken, error) {
+	query := `SELECT ` + columns + ` FROM personal_access_tokens WHERE role=$1 AND user_id=$2 ORDER BY created_at`
+
+	tokens := []models.PersonalToken{}
+	if err := tx.Txm().SelectContext(ctx, &tokens, query, role, userId); err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return tokens, nil
+}
+
+// TouchTX обновляет время последнего использования, timestamp в миллисекундах
+func (r *repositoryPG) TouchTX(ctx context.Context, tx transactions.Transaction, id string, timestamp int64) error {
+	query := `UPDATE personal_access_tokens SET last_used_at=$2 WHERE id=$1`
+	if _, err := tx.Txm().ExecContext(ctx, query, id, timestamp); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// DeleteByHashTX отзыв по самому токену (RFC 7009), отсутствие токена не ошибка
+func (r *repositoryPG) DeleteByHashTX(ctx context.Context, tx transactions.Transaction, tokenHash string) error {
+	query := `DELETE FROM personal_access_tokens WHERE token_hash=$1`
+	if _, err := tx.Txm().ExecContext(ctx, query, tokenHash); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) DeleteTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId, id string) error {
+	query := `DELETE FROM personal_access_tokens WHERE role=$1 AND user_id=$2 AND id=$3`
+	res, err := tx.Txm().ExecContext(ctx, query, role, userId, id)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+	if rowsAffected == 0 {
+		return errors.PersonalTokenDoesNotExist
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) DeleteAllTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) error {
+	query := `DELETE FROM personal_access_tokens WHERE role=$1 AND user_id=$2`
+	if _, err := tx.Txm().ExecContext(ctx, query, role, userId); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// DropExpiredTX удаляет не больше limit протухших токенов, бессрочные (expires_at=0) не трогает
+func (r *repositoryPG) DropExpiredTX(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	query := `
+		DELETE FROM personal_access_tokens WHERE id IN (
+			SELECT id FROM personal_access_tokens WHERE expires_at>0 AND expires_at<=$1 LIMIT $2
+		)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
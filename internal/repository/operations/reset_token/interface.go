@@ -11,4 +11,6 @@ type Repository interface {
 	Create(ctx context.Context, tx transactions.Transaction, rt models.ResetToken) (models.ResetToken, error)
 	GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ResetToken, error)
 	DeleteById(ctx context.Context, tx transactions.Transaction, id string) error
+	DeleteByUserId(ctx context.Context, tx transactions.Transaction, userId string) error
+	DeleteExpired(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
 }
@@ -0,0 +1,92 @@
+package reset_token
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
+)
+
+type repositoryMemory struct {
+	log    logger.Logger
+	tokens *db.MemoryTable[models.ResetToken]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:    log.Named("memory_reset_tokens"),
+		tokens: db.MemoryTableOf[models.ResetToken](client, "reset_tokens"),
+	}
+}
+
+// Create id токена генерируется при вставке
+func (r *repositoryMemory) Create(ctx context.Context, tx transactions.Transaction, rt models.ResetToken) (models.ResetToken, error) {
+	if rt.ID.IsNil() {
+		rt.ID = xid.New()
+	}
+
+	if err := r.tokens.Insert(transactions.Memory(tx), rt); err != nil {
+		return models.ResetToken{}, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert reset_tokens")
+	}
+
+	return rt, nil
+}
+
+func (r *repositoryMemory) GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ResetToken, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.ResetToken) bool {
+		return t.ID.String() == id
+	})
+	if err != nil {
+		return models.ResetToken{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select reset_tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.ResetToken{}, errors.ResetTokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) DeleteById(ctx context.Context, tx transactions.Transaction, id string) error {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.ResetToken) bool {
+		return t.ID.String() == id
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete reset_tokens")
+	}
+
+	if removed == 0 {
+		return errors.ResetTokenDoesNotExist
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteByUserId(ctx context.Context, tx transactions.Transaction, userId string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.ResetToken) bool {
+		return t.UserId.String() == userId
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete reset_tokens")
+	}
+
+	return nil
+}
+
+// DeleteExpired timestamp в секундах
+func (r *repositoryMemory) DeleteExpired(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.ResetToken) bool {
+		return t.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete reset_tokens")
+	}
+
+	return removed, nil
+}
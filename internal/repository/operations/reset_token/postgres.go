@@ -4,10 +4,13 @@ import (
 	"context"
 
 	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
 	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
 	"github.com/warehouse/auth-service/internal/pkg/logger"
 	"github.com/warehouse/auth-service/internal/repository/models"
 	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
 )
 
 type repositoryPG struct {
@@ -22,11 +25,15 @@ func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
 	}
 }
 
+// Create id токена генерируется при вставке
 func (r *repositoryPG) Create(ctx context.Context, tx transactions.Transaction, rt models.ResetToken) (models.ResetToken, error) {
 	query := `
-    INSERT INTO reset_tokens (user_id, token)
-    VALUES(:user_id, :token)
+    INSERT INTO reset_tokens (id, user_id, token, created_at, expires_at)
+    VALUES(:id, :user_id, :token, :created_at, :expires_at)
   `
+	if rt.ID.IsNil() {
+		rt.ID = xid.New()
+	}
 
 	res, err := tx.Txm().NamedExecContext(ctx, query, rt)
 	if err != nil {
@@ -52,18 +59,67 @@ func (r *repositoryPG) GetById(ctx context.Context, tx transactions.Transaction,
 		return models.ResetToken{}, err
 	}
 
+	if len(list) == 0 {
+		return models.ResetToken{}, errors.ResetTokenDoesNotExist
+	}
+
 	return list[0], nil
 }
 
+// DeleteById токен одноразовый: если его уже удалила параллельная транзакция, возвращается ResetTokenDoesNotExist
 func (r *repositoryPG) DeleteById(
 	ctx context.Context,
 	tx transactions.Transaction,
 	id string,
 ) error {
 	query := `DELETE FROM reset_tokens WHERE id=$1`
-	_, err := tx.Txm().ExecContext(ctx, query, id)
+	res, err := tx.Txm().ExecContext(ctx, query, id)
 	if err != nil {
 		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
 	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	if rowsAffected == 0 {
+		return errors.ResetTokenDoesNotExist
+	}
+
 	return nil
 }
+
+func (r *repositoryPG) DeleteByUserId(
+	ctx context.Context,
+	tx transactions.Transaction,
+	userId string,
+) error {
+	query := `DELETE FROM reset_tokens WHERE user_id=$1`
+	if _, err := tx.Txm().ExecContext(ctx, query, userId); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// DeleteExpired удаляет не больше limit протухших токенов, timestamp в секундах
+func (r *repositoryPG) DeleteExpired(
+	ctx context.Context,
+	tx transactions.Transaction,
+	timestamp int64,
+	limit int,
+) (int64, error) {
+	query := `DELETE FROM reset_tokens WHERE id IN (SELECT id FROM reset_tokens WHERE expires_at<=$1 LIMIT $2)`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,16 @@
+package service_client
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	Create(ctx context.Context, tx transactions.Transaction, client models.ServiceClient) error
+	GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ServiceClient, error)
+	List(ctx context.Context, tx transactions.Transaction) ([]models.ServiceClient, error)
+	UpdateSecret(ctx context.Context, tx transactions.Transaction, id, secretHash string) error
+	DeleteById(ctx context.Context, tx transactions.Transaction, id string) error
+}
@@ -0,0 +1,90 @@
+package service_client
+
+import (
+	"context"
+	"sort"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log     logger.Logger
+	clients *db.MemoryTable[models.ServiceClient]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:     log.Named("memory_service_clients"),
+		clients: db.MemoryTableOf[models.ServiceClient](client, "service_clients"),
+	}
+}
+
+func (r *repositoryMemory) Create(ctx context.Context, tx transactions.Transaction, client models.ServiceClient) error {
+	if err := r.clients.Insert(transactions.Memory(tx), client); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert service_clients")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ServiceClient, error) {
+	clients, err := r.clients.Select(transactions.Memory(tx), func(c models.ServiceClient) bool {
+		return c.Id == id
+	})
+	if err != nil {
+		return models.ServiceClient{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select service_clients")
+	}
+
+	if len(clients) == 0 {
+		return models.ServiceClient{}, errors.ServiceClientDoesNotExist
+	}
+
+	return clients[0], nil
+}
+
+func (r *repositoryMemory) List(ctx context.Context, tx transactions.Transaction) ([]models.ServiceClient, error) {
+	clients, err := r.clients.Select(transactions.Memory(tx), func(models.ServiceClient) bool { return true })
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select service_clients")
+	}
+
+	sort.SliceStable(clients, func(i, j int) bool { return clients[i].CreatedAt < clients[j].CreatedAt })
+	return clients, nil
+}
+
+func (r *repositoryMemory) UpdateSecret(ctx context.Context, tx transactions.Transaction, id, secretHash string) error {
+	updated, err := r.clients.Update(transactions.Memory(tx), func(c models.ServiceClient) bool {
+		return c.Id == id
+	}, func(c *models.ServiceClient) {
+		c.SecretHash = secretHash
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update service_clients")
+	}
+
+	if updated == 0 {
+		return errors.ServiceClientDoesNotExist
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) DeleteById(ctx context.Context, tx transactions.Transaction, id string) error {
+	removed, err := r.clients.Delete(transactions.Memory(tx), func(c models.ServiceClient) bool {
+		return c.Id == id
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete service_clients")
+	}
+
+	if removed == 0 {
+		return errors.ServiceClientDoesNotExist
+	}
+
+	return nil
+}
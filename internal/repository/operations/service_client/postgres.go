@@ -0,0 +1,98 @@
+package service_client
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_service_clients"),
+	}
+}
+
+func (r *repositoryPG) Create(ctx context.Context, tx transactions.Transaction, client models.ServiceClient) error {
+	query := `
+		INSERT INTO service_clients (id, name, secret_hash, scope, created_at)
+		VALUES(:id, :name, :secret_hash, :scope, :created_at)
+	`
+	if _, err := tx.Txm().NamedExecContext(ctx, query, client); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) GetById(ctx context.Context, tx transactions.Transaction, id string) (models.ServiceClient, error) {
+	query := `SELECT id, name, secret_hash, scope, created_at FROM service_clients WHERE id=$1`
+
+	var clients []models.ServiceClient
+	if err := tx.Txm().SelectContext(ctx, &clients, query, id); err != nil {
+		return models.ServiceClient{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	if len(clients) == 0 {
+		return models.ServiceClient{}, errors.ServiceClientDoesNotExist
+	}
+
+	return clients[0], nil
+}
+
+func (r *repositoryPG) List(ctx context.Context, tx transactions.Transaction) ([]models.ServiceClient, error) {
+	query := `SELECT id, name, secret_hash, scope, created_at FROM service_clients ORDER BY created_at`
+
+	clients := []models.ServiceClient{}
+	if err := tx.Txm().SelectContext(ctx, &clients, query); err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return clients, nil
+}
+
+func (r *repositoryPG) UpdateSecret(ctx context.Context, tx transactions.Transaction, id, secretHash string) error {
+	query := `UPDATE service_clients SET secret_hash=$2 WHERE id=$1`
+	res, err := tx.Txm().ExecContext(ctx, query, id, secretHash)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+	if rowsAffected == 0 {
+		return errors.ServiceClientDoesNotExist
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) DeleteById(ctx context.Context, tx transactions.Transaction, id string) error {
+	query := `DELETE FROM service_clients WHERE id=$1`
+	res, err := tx.Txm().ExecContext(ctx, query, id)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+	if rowsAffected == 0 {
+		return errors.ServiceClientDoesNotExist
+	}
+
+	return nil
+}
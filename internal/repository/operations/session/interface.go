@@ -0,0 +1,17 @@
+package session
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type Repository interface {
+	UpsertTX(ctx context.Context, tx transactions.Transaction, session models.Session) error
+	GetTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64) (models.Session, error)
+	TouchTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, timestamp int64) error
+	GetActiveTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, timestamp int64) ([]models.Session, error)
+	DropOrphanedTX(ctx context.Context, tx transactions.Transaction, limit int) (int64, error)
+}
@@ -0,0 +1,135 @@
+package session
+
+import (
+	"context"
+	"sort"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryMemory struct {
+	log      logger.Logger
+	sessions *db.MemoryTable[models.Session]
+	// tokens таблица jwt репозитория, по ней определяется, жива ли сессия
+	tokens *db.MemoryTable[models.Token]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:      log.Named("memory_sessions"),
+		sessions: db.MemoryTableOf[models.Session](client, "sessions"),
+		tokens:   db.MemoryTableOf[models.Token](client, "tokens"),
+	}
+}
+
+func (r *repositoryMemory) UpsertTX(ctx context.Context, tx transactions.Transaction, session models.Session) error {
+	memTx := transactions.Memory(tx)
+	updated, err := r.sessions.Update(memTx, func(s models.Session) bool {
+		return s.Role == session.Role && s.UserId == session.UserId && s.Number == session.Number
+	}, func(s *models.Session) {
+		*s = session
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update sessions")
+	}
+
+	if updated == 0 {
+		if err = r.sessions.Insert(memTx, session); err != nil {
+			return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert sessions")
+		}
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) GetTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64,
+) (models.Session, error) {
+	sessions, err := r.sessions.Select(transactions.Memory(tx), func(s models.Session) bool {
+		return s.Role == int64(role) && s.UserId == userId && s.Number == number
+	})
+	if err != nil {
+		return models.Session{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select sessions")
+	}
+
+	if len(sessions) == 0 {
+		return models.Session{}, errors.SessionDoesNotExist
+	}
+
+	return sessions[0], nil
+}
+
+func (r *repositoryMemory) TouchTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, timestamp int64,
+) error {
+	_, err := r.sessions.Update(transactions.Memory(tx), func(s models.Session) bool {
+		return s.Role == int64(role) && s.UserId == userId && s.Number == number
+	}, func(s *models.Session) {
+		s.LastUsedAt = timestamp
+	})
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "update sessions")
+	}
+
+	return nil
+}
+
+func (r *repositoryMemory) GetActiveTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, timestamp int64,
+) ([]models.Session, error) {
+	memTx := transactions.Memory(tx)
+	tokens, err := r.tokens.Select(memTx, func(t models.Token) bool {
+		return t.Role == int64(role) && t.UserId == userId && t.ExpiresAt > timestamp
+	})
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select tokens")
+	}
+
+	alive := make(map[int64]bool, len(tokens))
+	for _, t := range tokens {
+		alive[t.Number] = true
+	}
+
+	sessions, err := r.sessions.Select(memTx, func(s models.Session) bool {
+		return s.Role == int64(role) && s.UserId == userId && alive[s.Number]
+	})
+	if err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select sessions")
+	}
+
+	sort.SliceStable(sessions, func(i, j int) bool { return sessions[i].LastUsedAt > sessions[j].LastUsedAt })
+	return sessions, nil
+}
+
+func (r *repositoryMemory) DropOrphanedTX(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+	memTx := transactions.Memory(tx)
+	tokens, err := r.tokens.Select(memTx, func(models.Token) bool { return true })
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select tokens")
+	}
+
+	type sessionKey struct {
+		role   int64
+		userId string
+		number int64
+	}
+	withTokens := make(map[sessionKey]bool, len(tokens))
+	for _, t := range tokens {
+		withTokens[sessionKey{t.Role, t.UserId, t.Number}] = true
+	}
+
+	removed, err := r.sessions.Delete(memTx, func(s models.Session) bool {
+		return !withTokens[sessionKey{s.Role, s.UserId, s.Number}]
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete sessions")
+	}
+
+	return removed, nil
+}
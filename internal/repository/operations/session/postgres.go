@@ -0,0 +1,127 @@
+package session
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+)
+
+type repositoryPG struct {
+	log logger.Logger
+	pg  *db.PostgresClient
+}
+
+func NewPGRepository(log logger.Logger, client *db.PostgresClient) Repository {
+	return &repositoryPG{
+		pg:  client,
+		log: log.Named("pg_sessions"),
+	}
+}
+
+// UpsertTX номера сессий переиспользуются, поэтому запись освободившегося номера перезаписывается
+func (r *repositoryPG) UpsertTX(ctx context.Context, tx transactions.Transaction, session models.Session) error {
+	query := `
+		INSERT INTO sessions (role, user_id, number, ip, user_agent, created_at, last_used_at, scope, jkt, auth_time, amr, actor_id)
+		VALUES(:role, :user_id, :number, :ip, :user_agent, :created_at, :last_used_at, :scope, :jkt, :auth_time, :amr, :actor_id)
+		ON CONFLICT (role, user_id, number) DO UPDATE SET
+			ip=EXCLUDED.ip,
+			user_agent=EXCLUDED.user_agent,
+			created_at=EXCLUDED.created_at,
+			last_used_at=EXCLUDED.last_used_at,
+			scope=EXCLUDED.scope,
+			jkt=EXCLUDED.jkt,
+			auth_time=EXCLUDED.auth_time,
+			amr=EXCLUDED.amr,
+			actor_id=EXCLUDED.actor_id
+	`
+	if _, err := tx.Txm().NamedExecContext(ctx, query, session); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+func (r *repositoryPG) GetTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64,
+) (models.Session, error) {
+	query := `
+		SELECT role, user_id, number, ip, user_agent, created_at, last_used_at, scope, jkt, auth_time, amr, actor_id
+		FROM sessions
+		WHERE role=$1 AND user_id=$2 AND number=$3
+	`
+
+	var sessions []models.Session
+	if err := tx.Txm().SelectContext(ctx, &sessions, query, role, userId, number); err != nil {
+		return models.Session{}, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	if len(sessions) == 0 {
+		return models.Session{}, errors.SessionDoesNotExist
+	}
+
+	return sessions[0], nil
+}
+
+func (r *repositoryPG) TouchTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, timestamp int64,
+) error {
+	query := `UPDATE sessions SET last_used_at=$4 WHERE role=$1 AND user_id=$2 AND number=$3`
+	if _, err := tx.Txm().ExecContext(ctx, query, role, userId, number, timestamp); err != nil {
+		return r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	return nil
+}
+
+// GetActiveTX сессия активна, пока жив хотя бы один ее токен: у сессий имперсонации и сервисных
+// сессий refresh токена нет. timestamp в миллисекундах
+func (r *repositoryPG) GetActiveTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, timestamp int64,
+) ([]models.Session, error) {
+	query := `
+		SELECT s.role, s.user_id, s.number, s.ip, s.user_agent, s.created_at, s.last_used_at, s.scope, s.jkt, s.auth_time, s.amr, s.actor_id
+		FROM sessions s
+		WHERE s.role=$1 AND s.user_id=$2 AND EXISTS (
+			SELECT 1 FROM tokens t
+			WHERE t.role=s.role AND t.user_id=s.user_id AND t.number=s.number AND t.expires_at>$3
+		)
+		ORDER BY s.last_used_at DESC
+	`
+
+	sessions := []models.Session{}
+	if err := tx.Txm().SelectContext(ctx, &sessions, query, role, userId, timestamp); err != nil {
+		return nil, r.log.ErrorRepo(err, repository_errors.PostgresqlGetRaw, query)
+	}
+
+	return sessions, nil
+}
+
+// DropOrphanedTX удаляет не больше limit сессий, у которых не осталось токенов
+func (r *repositoryPG) DropOrphanedTX(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+	query := `
+		DELETE FROM sessions WHERE ctid IN (
+			SELECT s.ctid FROM sessions s
+			WHERE NOT EXISTS (
+				SELECT 1 FROM tokens t WHERE t.role=s.role AND t.user_id=s.user_id AND t.number=s.number
+			)
+			LIMIT $1
+		)
+	`
+	res, err := tx.Txm().ExecContext(ctx, query, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
@@ -11,6 +11,7 @@ import (
 type (
 	Repository interface {
 		StartTransaction(ctx context.Context) (Transaction, error)
+		StartReadOnlyTransaction(ctx context.Context) (Transaction, error)
 	}
 
 	Transaction interface {
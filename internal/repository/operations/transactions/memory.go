@@ -0,0 +1,51 @@
+package transactions
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type (
+	MemoryTx struct {
+		*db.MemoryTx
+	}
+
+	repositoryMemory struct {
+		client *db.MemoryClient
+	}
+)
+
+func NewMemoryRepository(client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		client: client,
+	}
+}
+
+func (repo *repositoryMemory) StartTransaction(ctx context.Context) (Transaction, error) {
+	tx, err := repo.client.Begin(ctx, false)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryTx{MemoryTx: tx}, nil
+}
+
+func (repo *repositoryMemory) StartReadOnlyTransaction(ctx context.Context) (Transaction, error) {
+	tx, err := repo.client.Begin(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	return &MemoryTx{MemoryTx: tx}, nil
+}
+
+// Txm у транзакции в памяти нет sql транзакции
+func (t *MemoryTx) Txm() *sqlx.Tx {
+	return nil
+}
+
+// Memory транзакция хранилища в памяти, memory репозитории работают только с ней
+func Memory(tx Transaction) *db.MemoryTx {
+	return tx.(*MemoryTx).MemoryTx
+}
@@ -2,6 +2,7 @@ package transactions
 
 import (
 	"context"
+	"database/sql"
 
 	"github.com/warehouse/auth-service/internal/db"
 
@@ -36,6 +37,18 @@ func (repo *repositoryPG) StartTransaction(ctx context.Context) (Transaction, er
 	}, nil
 }
 
+// StartReadOnlyTransaction транзакция только на чтение, не блокирует строки и может идти на реплику
+func (repo *repositoryPG) StartReadOnlyTransaction(ctx context.Context) (Transaction, error) {
+	tx, err := repo.client.DB.BeginTxx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{
+		Tx:  tx,
+		ctx: ctx,
+	}, nil
+}
+
 // Txm - get transaction method
 func (t *Tx) Txm() *sqlx.Tx {
 	return t.Tx
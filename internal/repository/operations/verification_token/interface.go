@@ -11,4 +11,5 @@ type Repository interface {
 	Create(ctx context.Context, tx transactions.Transaction, vt models.VerificationToken) (models.VerificationToken, error)
 	GetById(ctx context.Context, tx transactions.Transaction, id string) (models.VerificationToken, error)
 	DeleteById(ctx context.Context, tx transactions.Transaction, id string) error
+	DeleteExpired(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error)
 }
@@ -0,0 +1,77 @@
+package verification_token
+
+import (
+	"context"
+
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/errors/repository_errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
+)
+
+type repositoryMemory struct {
+	log    logger.Logger
+	tokens *db.MemoryTable[models.VerificationToken]
+}
+
+func NewMemoryRepository(log logger.Logger, client *db.MemoryClient) Repository {
+	return &repositoryMemory{
+		log:    log.Named("memory_verification_tokens"),
+		tokens: db.MemoryTableOf[models.VerificationToken](client, "verification_tokens"),
+	}
+}
+
+// Create id токена генерируется при вставке
+func (r *repositoryMemory) Create(ctx context.Context, tx transactions.Transaction, vt models.VerificationToken) (models.VerificationToken, error) {
+	if vt.ID.IsNil() {
+		vt.ID = xid.New()
+	}
+
+	if err := r.tokens.Insert(transactions.Memory(tx), vt); err != nil {
+		return models.VerificationToken{}, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "insert verification_tokens")
+	}
+
+	return vt, nil
+}
+
+func (r *repositoryMemory) GetById(ctx context.Context, tx transactions.Transaction, id string) (models.VerificationToken, error) {
+	tokens, err := r.tokens.Select(transactions.Memory(tx), func(t models.VerificationToken) bool {
+		return t.ID.String() == id
+	})
+	if err != nil {
+		return models.VerificationToken{}, r.log.ErrorRepo(err, repository_errors.MemoryGetRaw, "select verification_tokens")
+	}
+
+	if len(tokens) == 0 {
+		return models.VerificationToken{}, errors.VerificationTokenDoesNotExist
+	}
+
+	return tokens[0], nil
+}
+
+func (r *repositoryMemory) DeleteById(ctx context.Context, tx transactions.Transaction, id string) error {
+	_, err := r.tokens.Delete(transactions.Memory(tx), func(t models.VerificationToken) bool {
+		return t.ID.String() == id
+	}, 0)
+	if err != nil {
+		return r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete verification_tokens")
+	}
+
+	return nil
+}
+
+// DeleteExpired timestamp в секундах
+func (r *repositoryMemory) DeleteExpired(ctx context.Context, tx transactions.Transaction, timestamp int64, limit int) (int64, error) {
+	removed, err := r.tokens.Delete(transactions.Memory(tx), func(t models.VerificationToken) bool {
+		return t.ExpiresAt <= timestamp
+	}, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.MemoryExecRaw, "delete verification_tokens")
+	}
+
+	return removed, nil
+}
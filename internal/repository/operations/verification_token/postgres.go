@@ -75,3 +75,24 @@ func (r *repositoryPG) DeleteById(
 	}
 	return nil
 }
+
+// DeleteExpired удаляет не больше limit протухших токенов, timestamp в секундах
+func (r *repositoryPG) DeleteExpired(
+	ctx context.Context,
+	tx transactions.Transaction,
+	timestamp int64,
+	limit int,
+) (int64, error) {
+	query := `DELETE FROM verification_tokens WHERE id IN (SELECT id FROM verification_tokens WHERE expires_at<=$1 LIMIT $2)`
+	res, err := tx.Txm().ExecContext(ctx, query, timestamp, limit)
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlExecRaw, query)
+	}
+
+	rowsAffected, err := res.RowsAffected()
+	if err != nil {
+		return 0, r.log.ErrorRepo(err, repository_errors.PostgresqlRowsAffectedRaw, query)
+	}
+
+	return rowsAffected, nil
+}
@@ -0,0 +1,93 @@
+package scheduler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+type (
+	// Job периодическая задача. Run не должен выполняться дольше Interval
+	Job struct {
+		Name     string
+		Interval time.Duration
+		Run      func(ctx context.Context) error
+	}
+
+	Scheduler interface {
+		Start()
+		Stop() error
+	}
+
+	scheduler struct {
+		log  logger.Logger
+		jobs []Job
+
+		ctx    context.Context
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+	}
+)
+
+func NewScheduler(log logger.Logger, jobs ...Job) Scheduler {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &scheduler{
+		log:    log.Named("scheduler"),
+		jobs:   jobs,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *scheduler) Start() {
+	for _, job := range s.jobs {
+		if job.Interval <= 0 {
+			s.log.Zap().Warn("job disabled, interval is not set", zap.String("job", job.Name))
+			continue
+		}
+
+		s.log.Zap().Info("Start job", zap.String("job", job.Name), zap.Duration("interval", job.Interval))
+		s.wg.Add(1)
+		go s.loop(job)
+	}
+}
+
+func (s *scheduler) Stop() error {
+	s.log.Zap().Info("Stop scheduler")
+
+	s.cancel()
+	s.wg.Wait()
+	return nil
+}
+
+func (s *scheduler) loop(job Job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.run(job)
+		}
+	}
+}
+
+func (s *scheduler) run(job Job) {
+	ctx, cancel := context.WithTimeout(s.ctx, job.Interval)
+	defer cancel()
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		s.log.Zap().Error("job failed", zap.String("job", job.Name), zap.Error(err))
+		return
+	}
+	s.log.Zap().Info("job finished", zap.String("job", job.Name), zap.Duration("took", time.Since(start)))
+}
@@ -24,8 +24,9 @@ type appServer struct {
 	wg       sync.WaitGroup
 	listener net.Listener
 
-	middleware    middlewares.Middleware
-	authEndpoints internalHttp.Handler
+	middleware         middlewares.Middleware
+	authEndpoints      internalHttp.Handler
+	wellKnownEndpoints internalHttp.Handler
 }
 
 func (a *appServer) Start() {
@@ -61,6 +62,7 @@ func NewHttpServer(
 
 	middleware middlewares.Middleware,
 	authEndpoints internalHttp.Handler,
+	wellKnownEndpoints internalHttp.Handler,
 ) (Server, error) {
 	var err error
 	listener, err := net.Listen("tcp", fmt.Sprintf(":%v", cfg.Port))
@@ -76,9 +78,10 @@ func NewHttpServer(
 			Addr:    fmt.Sprintf(":%d", cfg.Port),
 			Handler: router,
 		},
-		listener:      listener,
-		middleware:    middleware,
-		authEndpoints: authEndpoints,
+		listener:           listener,
+		middleware:         middleware,
+		authEndpoints:      authEndpoints,
+		wellKnownEndpoints: wellKnownEndpoints,
 	}
 	server.initRoutes(router)
 	return server, nil
@@ -88,4 +91,5 @@ func (s *appServer) initRoutes(router *mux.Router) {
 	router.Use(s.middleware.QueueMiddleware)
 
 	s.authEndpoints.FillHandlers(router)
+	s.wellKnownEndpoints.FillHandlers(router)
 }
@@ -2,6 +2,7 @@ package auth
 
 import (
 	"context"
+	"crypto/subtle"
 	"time"
 
 	mailAdpt "github.com/warehouse/auth-service/internal/adapter/mail"
@@ -15,28 +16,43 @@ import (
 	"github.com/warehouse/auth-service/internal/pkg/utils/encode"
 	"github.com/warehouse/auth-service/internal/pkg/utils/str"
 	rep_converters "github.com/warehouse/auth-service/internal/repository/converters"
+	repModels "github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/audit"
 	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
 	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
 	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
 	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
 	jwtSvc "github.com/warehouse/auth-service/internal/service/jwt"
+	lockoutSvc "github.com/warehouse/auth-service/internal/service/lockout"
+	passwordSvc "github.com/warehouse/auth-service/internal/service/password"
 
+	"go.uber.org/zap"
 	"golang.org/x/crypto/bcrypt"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/status"
 )
 
+const (
+	passwordHashCost = 12
+	resetTokenLength = 32
+	// dummyPasswordHash хеш с той же стоимостью, что и настоящие. С ним сравнивается пароль неизвестного пользователя,
+	// чтобы ответ занимал столько же времени и не выдавал, существует ли логин
+	dummyPasswordHash = "$2a$12$DLoUgbE5rGtbrJsyauxDuej2doolAS/eai2z/9FzphqBj4q3yoCl."
+)
+
 // TODO:
 // - Add confirm verification token
-// - Add confirm reset token
 type (
 	Service interface {
-		Login(ctx context.Context, reqData models.LoginRequestData) (*domain.Account, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
+		Login(ctx context.Context, reqData models.LoginRequestData, meta domain.SessionMeta) (*domain.Account, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
 		Register(ctx context.Context, reqData models.CreateRequestData) (string, *errors.Error)
 		FullLogout(ctx context.Context, role domain.Role, accId string) *errors.Error
 		CheckVerificationToken(ctx context.Context, vt, accId, tokenId string) (domain.Account, *errors.Error)
 		CreateResetToken(ctx context.Context, email string) *errors.Error
-		VerifyResetToken(ctx context.Context, token, tokenId, accId string) *errors.Error
+		ResetPassword(ctx context.Context, reqData models.PasswordResetConfirmRequest) *errors.Error
+		ChangePassword(ctx context.Context, acc domain.Account, reqData models.PasswordChangeRequest, meta domain.SessionMeta) *errors.Error
+		Impersonate(ctx context.Context, admin domain.Account, reqData models.ImpersonateRequest, meta domain.SessionMeta) (domain.JwtTokenInfo, *errors.Error)
 	}
 
 	service struct {
@@ -46,6 +62,11 @@ type (
 		jwtRepo          jwtRepo.Repository
 		verificationRepo verification_token.Repository
 		resetRepo        reset_token.Repository
+		auditRepo        audit.Repository
+		personalRepo     personal_token.Repository
+
+		lockoutService  lockoutSvc.Service
+		passwordService passwordSvc.Service
 
 		log        logger.Logger
 		jwtService jwtSvc.Service
@@ -67,6 +88,10 @@ func NewService(
 	mailAdapter mailAdpt.Adapter,
 	verificationRepo verification_token.Repository,
 	resetRepo reset_token.Repository,
+	auditRepo audit.Repository,
+	personalRepo personal_token.Repository,
+	lockoutService lockoutSvc.Service,
+	passwordService passwordSvc.Service,
 ) Service {
 	return &service{
 		cfg:              cfg,
@@ -79,50 +104,189 @@ func NewService(
 		mailAdapter:      mailAdapter,
 		verificationRepo: verificationRepo,
 		resetRepo:        resetRepo,
+		auditRepo:        auditRepo,
+		personalRepo:     personalRepo,
+		lockoutService:   lockoutService,
+		passwordService:  passwordService,
 	}
 }
 
-func (s *service) VerifyResetToken(ctx context.Context, token, tokenId, accId string) *errors.Error {
+// ResetPassword подтверждение сброса. Пароль проверяется политикой и хешируется только после проверки токена:
+// иначе без токена по любому acc_id можно было бы узнавать логин и почту по ошибкам политики и нагружать bcrypt.
+// Токен удаляется до смены пароля, поэтому из параллельных запросов с одним токеном пройдет только один.
+// После смены пароля отзываются все сессии, персональные токены и остальные запросы на сброс
+func (s *service) ResetPassword(ctx context.Context, reqData models.PasswordResetConfirmRequest) *errors.Error {
+	if reqData.Token == "" || reqData.TokenId == "" || reqData.AccId == "" || reqData.NewPassword == "" {
+		return errors.WD(errors.ValidationFailed, errors.New("token, token_id, acc_id and new_password are required"))
+	}
+
+	if e := s.checkResetToken(ctx, reqData); e != nil {
+		return e
+	}
+
+	acc, err := s.userAdapter.GetById(ctx, reqData.AccId)
+	if err != nil {
+		return s.log.ServiceGrpcAdapterError(err)
+	}
+
+	if e := s.passwordService.Validate("new_password", reqData.NewPassword, acc); e != nil {
+		return e
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(reqData.NewPassword), passwordHashCost)
+	if err != nil {
+		return s.log.ServiceError(errors.WD(errors.InternalError, err))
+	}
+
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
 		return s.log.ServiceTxError(err)
 	}
 	defer tx.Rollback()
 
-	// Check user is exists
-	if _, err = s.userAdapter.GetById(ctx, accId); err != nil {
-		return s.log.ServiceGrpcAdapterError(err)
+	// токен мог быть использован параллельным запросом, пока хешировался пароль
+	if err = s.resetRepo.DeleteById(ctx, tx, reqData.TokenId); err != nil {
+		if err == errors.ResetTokenDoesNotExist {
+			return errors.AuthInvalidToken
+		}
+		return s.log.ServiceDatabaseError(err)
 	}
 
-	hashedToken, err := encode.HashedPassword(token)
+	if e := s.replacePasswordTX(ctx, tx, acc, hash); e != nil {
+		return e
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	s.log.Info("password reset", zap.String("user_id", acc.Id))
+	s.notifyPasswordChanged(acc)
+
+	return nil
+}
+
+// checkResetToken токен выписан на конкретного пользователя, чужой acc_id с ним не проходит
+func (s *service) checkResetToken(ctx context.Context, reqData models.PasswordResetConfirmRequest) *errors.Error {
+	tx, err := s.txRepo.StartReadOnlyTransaction(ctx)
 	if err != nil {
-		return s.log.ServiceError(errors.WD(errors.InternalError, err))
+		return s.log.ServiceTxError(err)
 	}
+	defer tx.Rollback()
 
-	rt, err := s.resetRepo.GetById(ctx, tx, tokenId)
+	rt, err := s.resetRepo.GetById(ctx, tx, reqData.TokenId)
 	if err != nil {
+		if err == errors.ResetTokenDoesNotExist {
+			return errors.AuthInvalidToken
+		}
 		return s.log.ServiceDatabaseError(err)
 	}
 
-	if rt.ExpiresAt < time.Now().Unix() {
+	if rt.UserId.String() != reqData.AccId ||
+		subtle.ConstantTimeCompare([]byte(rt.Token), []byte(encode.HashToken(reqData.Token))) != 1 {
+		return errors.AuthInvalidToken
+	}
+
+	if rt.ExpiresAt < s.timeAdapter.Now().Unix() {
 		return errors.AuthExpiredToken
 	}
 
-	if rt.Token != hashedToken {
-		return errors.AuthInvalidToken
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
 	}
 
-	if err := s.resetRepo.DeleteById(ctx, tx, tokenId); err != nil {
-		return s.log.ServiceDatabaseError(err)
+	return nil
+}
+
+// ChangePassword смена пароля из профиля по текущему паролю. Как и после сброса, закрываются все сессии и персональные токены.
+// Текущий пароль проверяется под теми же счетчиками, что и при входе, иначе украденной сессией его можно было бы подбирать
+func (s *service) ChangePassword(
+	ctx context.Context, acc domain.Account, reqData models.PasswordChangeRequest, meta domain.SessionMeta,
+) *errors.Error {
+	if reqData.Password == "" || reqData.NewPassword == "" {
+		return errors.WD(errors.ValidationFailed, errors.New("password and new_password are required"))
 	}
 
-	if err := tx.Commit(); err != nil {
+	owner, err := s.userAdapter.GetById(ctx, acc.Id)
+	if err != nil {
+		return s.log.ServiceGrpcAdapterError(err)
+	}
+
+	if _, e := s.verifyPassword(ctx, owner.Username, reqData.Password, meta.Ip); e != nil {
+		return e
+	}
+
+	if e := s.passwordService.Validate("new_password", reqData.NewPassword, owner); e != nil {
+		return e
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(reqData.NewPassword), passwordHashCost)
+	if err != nil {
+		return s.log.ServiceError(errors.WD(errors.InternalError, err))
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if e := s.replacePasswordTX(ctx, tx, owner, hash); e != nil {
+		return e
+	}
+
+	if err = tx.Commit(); err != nil {
 		return s.log.ServiceTxError(err)
 	}
 
+	s.log.Info("password changed", zap.String("user_id", owner.Id))
+	s.notifyPasswordChanged(owner)
+
+	return nil
+}
+
+// replacePasswordTX отзывает все сессии, персональные токены и запросы на сброс, затем меняет хеш в пользовательском сервисе
+func (s *service) replacePasswordTX(ctx context.Context, tx transactions.Transaction, acc domain.Account, hash []byte) *errors.Error {
+	if err := s.resetRepo.DeleteByUserId(ctx, tx, acc.Id); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err := s.jwtRepo.DropAllTokensTX(ctx, tx, acc.Role, acc.Id); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	// персональный токен, созданный с украденной сессии, иначе пережил бы смену пароля
+	if err := s.personalRepo.DeleteAllTX(ctx, tx, acc.Role, acc.Id); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	// пароль меняется последним: если пользовательский сервис ответит ошибкой, токен и сессии останутся
+	success, err := s.userAdapter.ResetPassword(ctx, domain.ResetPasswordRequestData{Id: acc.Id, Password: string(hash)})
+	if err != nil {
+		return s.log.ServiceGrpcAdapterError(err)
+	}
+	if !success {
+		return s.log.ServiceError(errors.AuthPasswordNotChanged)
+	}
+
 	return nil
 }
 
+// notifyPasswordChanged пароль уже сменен, поэтому ошибка отправки письма только логируется
+func (s *service) notifyPasswordChanged(acc domain.Account) {
+	mail := domain.EmailMessage{
+		To:   acc.Email,
+		Type: domain.PasswordChangedType,
+		Payload: domain.Payload{
+			Firstname: acc.Firstname,
+		},
+	}
+
+	if err := s.mailAdapter.SendMessage(mail); err != nil {
+		s.log.ServiceBrokerAdapterError(err)
+	}
+}
+
 func (s *service) CreateResetToken(ctx context.Context, email string) *errors.Error {
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
@@ -135,15 +299,14 @@ func (s *service) CreateResetToken(ctx context.Context, email string) *errors.Er
 		return s.log.ServiceGrpcAdapterError(err)
 	}
 
-	token := str.RandomString(16)
-	hashedToken, err := encode.HashedPassword(token)
+	token, err := encode.RandomToken(resetTokenLength)
 	if err != nil {
 		return s.log.ServiceError(errors.WD(errors.InternalError, err))
 	}
 
 	rtInfo := domain.ResetTokenInfo{
 		UserId:    acc.Id,
-		Token:     hashedToken,
+		Token:     encode.HashToken(token),
 		CreatedAt: s.timeAdapter.Now().Unix(),
 		ExpiresAt: s.timeAdapter.AddTime(s.timeAdapter.Now(), time.Minute*15).Unix(),
 	}
@@ -236,24 +399,25 @@ func (s *service) CheckVerificationToken(ctx context.Context, vt string, accId s
 }
 
 func (s *service) Login(
-	ctx context.Context, reqData models.LoginRequestData,
+	ctx context.Context, reqData models.LoginRequestData, meta domain.SessionMeta,
 ) (*domain.Account, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
-	tx, err := s.txRepo.StartTransaction(ctx)
-	if err != nil {
-		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
-	}
-	defer tx.Rollback()
-
-	acc, err := s.userAdapter.GetByLogin(ctx, reqData.Login)
-	if err != nil {
-		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceGrpcAdapterError(err)
+	acc, e := s.verifyPassword(ctx, reqData.Login, reqData.Password, meta.Ip)
+	if e != nil {
+		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, e
 	}
 
 	if !acc.Verified {
 		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.AuthNotVerifiedAccount
 	}
 
-	accessToken, refreshToken, e := s.jwtService.CreateTokensTX(ctx, tx, acc.Role, acc.Id)
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	meta.Amr = []string{domain.AmrPassword}
+	accessToken, refreshToken, e := s.jwtService.CreateTokensTX(ctx, tx, acc.Role, acc.Id, meta)
 	if e != nil {
 		return nil, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, e
 	}
@@ -265,9 +429,57 @@ func (s *service) Login(
 	return &acc, accessToken, refreshToken, nil
 }
 
+// verifyPassword проверяет пароль под счетчиками неудачных входов. Попытка учитывается до bcrypt,
+// ошибки счетчика после проверки уже залогированы и не должны менять ответ
+func (s *service) verifyPassword(ctx context.Context, login, password, ip string) (domain.Account, *errors.Error) {
+	attempt, e := s.lockoutService.Attempt(ctx, login, ip)
+	if e != nil {
+		return domain.Account{}, e
+	}
+
+	acc, e := s.checkPassword(ctx, login, password)
+	switch e {
+	case nil:
+		_ = s.lockoutService.Succeed(ctx, attempt)
+	case errors.AuthInvalidCredentials:
+		s.lockoutService.Fail(ctx, attempt)
+	default:
+		_ = s.lockoutService.Release(ctx, attempt)
+	}
+
+	return acc, e
+}
+
+// checkPassword сверяет пароль с bcrypt хешем из пользовательского сервиса. Неизвестный логин и неверный пароль
+// дают одну и ту же ошибку, а для неизвестного логина пароль сравнивается с фиктивным хешем
+func (s *service) checkPassword(ctx context.Context, login, password string) (domain.Account, *errors.Error) {
+	creds, err := s.userAdapter.GetCredentials(ctx, login)
+	if err != nil {
+		if status.Code(err) != codes.NotFound {
+			return domain.Account{}, s.log.ServiceGrpcAdapterError(err)
+		}
+		_ = bcrypt.CompareHashAndPassword([]byte(dummyPasswordHash), []byte(password))
+		return domain.Account{}, errors.AuthInvalidCredentials
+	}
+
+	if err = bcrypt.CompareHashAndPassword([]byte(creds.Hash), []byte(password)); err != nil {
+		if err != bcrypt.ErrMismatchedHashAndPassword {
+			s.log.Zap().Warn("stored password hash is invalid", zap.String("user_id", creds.Account.Id), zap.Error(err))
+		}
+		return domain.Account{}, errors.AuthInvalidCredentials
+	}
+
+	return creds.Account, nil
+}
+
 func (s *service) Register(
 	ctx context.Context, reqData models.CreateRequestData,
 ) (string, *errors.Error) {
+	owner := domain.Account{Username: reqData.Username, Email: reqData.Email}
+	if e := s.passwordService.Validate("password", reqData.Password, owner); e != nil {
+		return "", e
+	}
+
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
 		return "", s.log.ServiceTxError(err)
@@ -294,7 +506,7 @@ func (s *service) Register(
 		}
 	}
 
-	hash, err := bcrypt.GenerateFromPassword([]byte(reqData.Password), 12)
+	hash, err := bcrypt.GenerateFromPassword([]byte(reqData.Password), passwordHashCost)
 	if err != nil {
 		return "", s.log.ServiceError(errors.WD(errors.InternalError, err))
 	}
@@ -347,3 +559,58 @@ func (s *service) Register(
 
 	return vt.ID.String(), nil
 }
+
+// Impersonate выдает админу access токен от имени пользователя. Каждый выпуск пишется в аудит
+func (s *service) Impersonate(
+	ctx context.Context, admin domain.Account, reqData models.ImpersonateRequest, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, *errors.Error) {
+	if reqData.UserId == "" || reqData.Reason == "" {
+		return domain.JwtTokenInfo{}, errors.WD(errors.ValidationFailed, errors.New("user_id and reason are required"))
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	target, err := s.userAdapter.GetById(ctx, reqData.UserId)
+	if err != nil {
+		return domain.JwtTokenInfo{}, s.log.ServiceGrpcAdapterError(err)
+	}
+	if target.Role == domain.RoleAdmin {
+		return domain.JwtTokenInfo{}, errors.WD(errors.PermissionDenied, errors.New("admins can not be impersonated"))
+	}
+
+	token, number, e := s.jwtService.CreateImpersonationTokenTX(ctx, tx, admin.Id, target.Role, target.Id, meta)
+	if e != nil {
+		return domain.JwtTokenInfo{}, e
+	}
+
+	record := repModels.ImpersonationAudit{
+		AdminId:      admin.Id,
+		TargetUserId: target.Id,
+		TargetRole:   int64(target.Role),
+		Number:       number,
+		Reason:       reqData.Reason,
+		Ip:           meta.Ip,
+		UserAgent:    meta.UserAgent,
+		CreatedAt:    s.timeAdapter.Now().UnixNano() / 1e+6,
+		ExpiresAt:    token.ExpiresAt,
+	}
+	if err = s.auditRepo.AddImpersonationTX(ctx, tx, record); err != nil {
+		return domain.JwtTokenInfo{}, s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+	}
+
+	s.log.Info("impersonation token issued",
+		zap.String("admin_id", admin.Id),
+		zap.String("target_user_id", target.Id),
+		zap.Int64("number", number),
+	)
+
+	return token, nil
+}
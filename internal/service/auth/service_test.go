@@ -0,0 +1,391 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	randomAdpt "github.com/warehouse/auth-service/internal/adapter/random"
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	userAdpt "github.com/warehouse/auth-service/internal/adapter/user"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/audit"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
+	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
+	sessionRepo "github.com/warehouse/auth-service/internal/repository/operations/session"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
+	jwtSvc "github.com/warehouse/auth-service/internal/service/jwt"
+	lockoutSvc "github.com/warehouse/auth-service/internal/service/lockout"
+	passwordSvc "github.com/warehouse/auth-service/internal/service/password"
+	patSvc "github.com/warehouse/auth-service/internal/service/pat"
+
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	testAudience = "warehouse"
+	testPassword = "Old-Passw0rd!"
+	newPassword  = "N3w-Passw0rd!xyz"
+)
+
+type (
+	// users пользовательский сервис в памяти. Хеши с минимальной стоимостью, чтобы тесты не ждали bcrypt
+	users struct {
+		userAdpt.Adapter
+		mu     sync.Mutex
+		byId   map[string]domain.Account
+		hashes map[string]string
+	}
+
+	mailbox struct {
+		mu       sync.Mutex
+		messages []domain.EmailMessage
+	}
+
+	fixture struct {
+		auth  Service
+		jwt   jwtSvc.Service
+		pat   patSvc.Service
+		users *users
+		mail  *mailbox
+	}
+)
+
+func (u *users) add(t *testing.T, username, password string) domain.Account {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acc := domain.Account{
+		Id:       xid.New().String(),
+		Username: username,
+		Email:    username + "@example.com",
+		Role:     domain.RoleUser,
+		Verified: true,
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	u.byId[acc.Id] = acc
+	u.hashes[acc.Id] = string(hash)
+	return acc
+}
+
+func (u *users) find(match func(domain.Account) bool) (domain.Account, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	for _, acc := range u.byId {
+		if match(acc) {
+			return acc, nil
+		}
+	}
+	return domain.Account{}, status.Error(codes.NotFound, "user not found")
+}
+
+func (u *users) GetById(ctx context.Context, userId string) (domain.Account, error) {
+	return u.find(func(acc domain.Account) bool { return acc.Id == userId })
+}
+
+func (u *users) GetByEmail(ctx context.Context, email string) (domain.Account, error) {
+	return u.find(func(acc domain.Account) bool { return acc.Email == email })
+}
+
+func (u *users) GetByLogin(ctx context.Context, username string) (domain.Account, error) {
+	return u.find(func(acc domain.Account) bool { return acc.Username == username })
+}
+
+func (u *users) GetCredentials(ctx context.Context, username string) (domain.Credentials, error) {
+	acc, err := u.GetByLogin(ctx, username)
+	if err != nil {
+		return domain.Credentials{}, err
+	}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return domain.Credentials{Account: acc, Hash: u.hashes[acc.Id]}, nil
+}
+
+func (u *users) ResetPassword(ctx context.Context, request domain.ResetPasswordRequestData) (bool, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	if _, ok := u.byId[request.Id]; !ok {
+		return false, nil
+	}
+	u.hashes[request.Id] = request.Password
+	return true, nil
+}
+
+func (m *mailbox) SendMessage(message domain.EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+// last последнее письмо нужного типа
+func (m *mailbox) last(t *testing.T, messageType domain.EmailType) domain.EmailMessage {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.messages) - 1; i >= 0; i-- {
+		if m.messages[i].Type == messageType {
+			return m.messages[i]
+		}
+	}
+	t.Fatalf("no %v message in %+v", messageType, m.messages)
+	return domain.EmailMessage{}
+}
+
+// newMemoryFixture сервис входа на хранилище в памяти, как при storage=memory
+func newMemoryFixture(t *testing.T, lockout config.Lockout) fixture {
+	t.Helper()
+
+	log := logger.NewLogger(zap.NewNop())
+	client := db.NewMemoryClient()
+	txRepo := transactions.NewMemoryRepository(client)
+	tokens := jwtRepo.NewMemoryRepository(log, client)
+	personal := personal_token.NewMemoryRepository(log, client)
+	timeAdapter := timeAdpt.NewAdapter(3)
+	u := &users{byId: map[string]domain.Account{}, hashes: map[string]string{}}
+	mail := &mailbox{}
+
+	jwtService, err := jwtSvc.NewService(
+		log,
+		txRepo,
+		tokens,
+		sessionRepo.NewMemoryRepository(log, client),
+		personal,
+		dpop.NewMemoryRepository(log, client),
+		config.Auth{
+			Keys:                []config.JwtKey{{Id: "test", SigningMethod: "HS256", Key: "testSecret"}},
+			ActiveKey:           "test",
+			Issuer:              "warehouse-auth",
+			Audience:            []string{testAudience},
+			ServiceAudience:     testAudience,
+			AccessTokenTimeout:  time.Minute,
+			RefreshTokenTimeout: time.Hour,
+		},
+		timeAdapter,
+		randomAdpt.NewAdapter(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	passwordService, err := passwordSvc.NewService(log, config.PasswordPolicy{MinLength: 10, MinClasses: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fixture{
+		auth: NewService(
+			config.Config{},
+			txRepo,
+			tokens,
+			timeAdapter,
+			jwtService,
+			log,
+			u,
+			mail,
+			verification_token.NewMemoryRepository(log, client),
+			reset_token.NewMemoryRepository(log, client),
+			audit.NewMemoryRepository(log, client),
+			personal,
+			lockoutSvc.NewService(log, lockout, txRepo, login_failure.NewMemoryRepository(log, client), timeAdapter, u, mail),
+			passwordService,
+		),
+		jwt:   jwtService,
+		pat:   patSvc.NewService(log, txRepo, personal, timeAdapter),
+		users: u,
+		mail:  mail,
+	}
+}
+
+func login(f fixture, username, password string) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
+	_, access, refresh, e := f.auth.Login(
+		context.Background(), models.LoginRequestData{Login: username, Password: password}, domain.SessionMeta{Ip: "10.0.0.1"},
+	)
+	return access, refresh, e
+}
+
+func expectLocked(t *testing.T, e *errors.Error) {
+	t.Helper()
+
+	if e == nil || e.Code != errors.AuthLoginLocked.Code || e.Reason != errors.AuthLoginLocked.Reason {
+		t.Fatalf("err = %v, want %v", e, errors.AuthLoginLocked)
+	}
+}
+
+func TestResetPasswordRevokesCredentials(t *testing.T) {
+	ctx := context.Background()
+	f := newMemoryFixture(t, config.Lockout{})
+	acc := f.users.add(t, "user", testPassword)
+
+	access, refresh, e := login(f, acc.Username, testPassword)
+	if e != nil {
+		t.Fatal(e)
+	}
+	_, pat, e := f.pat.Create(ctx, acc.Role, acc.Id, []string{"profile:read"}, models.CreatePersonalTokenRequest{Name: "ci"})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if e = f.auth.CreateResetToken(ctx, acc.Email); e != nil {
+		t.Fatal(e)
+	}
+	reset := f.mail.last(t, domain.ResetType).Payload.ResetPayload
+	confirm := models.PasswordResetConfirmRequest{
+		Token:       reset.Token,
+		TokenId:     reset.TokenId,
+		AccId:       reset.AccId,
+		NewPassword: newPassword,
+	}
+	if e = f.auth.ResetPassword(ctx, confirm); e != nil {
+		t.Fatal(e)
+	}
+	f.mail.last(t, domain.PasswordChangedType)
+
+	if _, _, e = f.jwt.Auth(ctx, access.Token, domain.PurposeAccess, testAudience); e == nil {
+		t.Error("access token issued before the reset must be rejected")
+	}
+	if _, _, _, e = f.jwt.ReCreateTokens(ctx, refresh.Token, ""); e == nil {
+		t.Error("refresh token issued before the reset must be rejected")
+	}
+	if _, _, e = f.jwt.Auth(ctx, pat, domain.PurposeAccess, testAudience); e == nil {
+		t.Error("personal access token created before the reset must be rejected")
+	}
+
+	if _, _, e = login(f, acc.Username, testPassword); e != errors.AuthInvalidCredentials {
+		t.Errorf("login with the old password err = %v, want %v", e, errors.AuthInvalidCredentials)
+	}
+	if _, _, e = login(f, acc.Username, newPassword); e != nil {
+		t.Errorf("login with the new password: %v", e)
+	}
+
+	confirm.NewPassword = "An0ther-Passw0rd!"
+	if e = f.auth.ResetPassword(ctx, confirm); e != errors.AuthInvalidToken {
+		t.Fatalf("second confirm err = %v, want %v", e, errors.AuthInvalidToken)
+	}
+}
+
+func TestResetPasswordRejectsForeignAccount(t *testing.T) {
+	ctx := context.Background()
+	f := newMemoryFixture(t, config.Lockout{})
+	victim := f.users.add(t, "victim", testPassword)
+	attacker := f.users.add(t, "attacker", testPassword)
+
+	if e := f.auth.CreateResetToken(ctx, attacker.Email); e != nil {
+		t.Fatal(e)
+	}
+	reset := f.mail.last(t, domain.ResetType).Payload.ResetPayload
+
+	e := f.auth.ResetPassword(ctx, models.PasswordResetConfirmRequest{
+		Token:       reset.Token,
+		TokenId:     reset.TokenId,
+		AccId:       victim.Id,
+		NewPassword: newPassword,
+	})
+	if e != errors.AuthInvalidToken {
+		t.Fatalf("err = %v, want %v", e, errors.AuthInvalidToken)
+	}
+	if _, _, e = login(f, victim.Username, testPassword); e != nil {
+		t.Fatalf("victim password must stay the same: %v", e)
+	}
+}
+
+// TestResetPasswordChecksTokenFirst без токена ошибки политики не должны выдавать логин и почту владельца acc_id
+func TestResetPasswordChecksTokenFirst(t *testing.T) {
+	ctx := context.Background()
+	f := newMemoryFixture(t, config.Lockout{})
+	victim := f.users.add(t, "victim", testPassword)
+
+	e := f.auth.ResetPassword(ctx, models.PasswordResetConfirmRequest{
+		Token:       "guess",
+		TokenId:     xid.New().String(),
+		AccId:       victim.Id,
+		NewPassword: "Victim-Passw0rd!",
+	})
+	if e != errors.AuthInvalidToken {
+		t.Fatalf("err = %v, want %v", e, errors.AuthInvalidToken)
+	}
+}
+
+func TestResetPasswordKeepsTokenOnWeakPassword(t *testing.T) {
+	ctx := context.Background()
+	f := newMemoryFixture(t, config.Lockout{})
+	acc := f.users.add(t, "user", testPassword)
+
+	if e := f.auth.CreateResetToken(ctx, acc.Email); e != nil {
+		t.Fatal(e)
+	}
+	reset := f.mail.last(t, domain.ResetType).Payload.ResetPayload
+	confirm := models.PasswordResetConfirmRequest{
+		Token:       reset.Token,
+		TokenId:     reset.TokenId,
+		AccId:       reset.AccId,
+		NewPassword: "weak",
+	}
+
+	e := f.auth.ResetPassword(ctx, confirm)
+	if e == nil || e.Code != errors.ValidationFailed.Code || e.Reason != errors.ValidationFailed.Reason {
+		t.Fatalf("err = %v, want %v", e, errors.ValidationFailed)
+	}
+
+	confirm.NewPassword = newPassword
+	if e = f.auth.ResetPassword(ctx, confirm); e != nil {
+		t.Fatalf("token must survive a rejected password: %v", e)
+	}
+}
+
+func TestLoginLocksAfterWrongPasswords(t *testing.T) {
+	f := newMemoryFixture(t, config.Lockout{Threshold: 3})
+	acc := f.users.add(t, "user", testPassword)
+
+	for i := 0; i < 3; i++ {
+		if _, _, e := login(f, acc.Username, "wrong"); e != errors.AuthInvalidCredentials {
+			t.Fatalf("attempt %d err = %v, want %v", i+1, e, errors.AuthInvalidCredentials)
+		}
+	}
+
+	_, _, e := login(f, acc.Username, testPassword)
+	expectLocked(t, e)
+	f.mail.last(t, domain.AccountLockedType)
+}
+
+func TestChangePasswordCountsWrongPasswords(t *testing.T) {
+	ctx := context.Background()
+	f := newMemoryFixture(t, config.Lockout{Threshold: 2})
+	acc := f.users.add(t, "user", testPassword)
+	meta := domain.SessionMeta{Ip: "10.0.0.2"}
+
+	for i := 0; i < 2; i++ {
+		e := f.auth.ChangePassword(ctx, acc, models.PasswordChangeRequest{Password: "wrong", NewPassword: newPassword}, meta)
+		if e != errors.AuthInvalidCredentials {
+			t.Fatalf("attempt %d err = %v, want %v", i+1, e, errors.AuthInvalidCredentials)
+		}
+	}
+
+	e := f.auth.ChangePassword(ctx, acc, models.PasswordChangeRequest{Password: testPassword, NewPassword: newPassword}, meta)
+	expectLocked(t, e)
+
+	_, _, e = login(f, acc.Username, testPassword)
+	expectLocked(t, e)
+}
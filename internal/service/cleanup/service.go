@@ -0,0 +1,166 @@
+package cleanup
+
+import (
+	"context"
+
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
+	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
+	sessionRepo "github.com/warehouse/auth-service/internal/repository/operations/session"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
+
+	"go.uber.org/zap"
+)
+
+const defaultBatchSize = 1000
+
+type (
+	Service interface {
+		DropExpired(ctx context.Context) *errors.Error
+	}
+
+	// batchDelete удаляет не больше limit строк и возвращает сколько удалено
+	batchDelete func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error)
+
+	service struct {
+		log       logger.Logger
+		batchSize int
+
+		txRepo           transactions.Repository
+		jwtRepo          jwtRepo.Repository
+		verificationRepo verification_token.Repository
+		resetRepo        reset_token.Repository
+		sessionRepo      sessionRepo.Repository
+		personalRepo     personal_token.Repository
+		dpopRepo         dpop.Repository
+		loginFailureRepo login_failure.Repository
+
+		timeAdapter timeAdpt.Adapter
+	}
+)
+
+func NewService(
+	log logger.Logger,
+	cfg config.Cleanup,
+	txRepo transactions.Repository,
+	jwtRepo jwtRepo.Repository,
+	verificationRepo verification_token.Repository,
+	resetRepo reset_token.Repository,
+	sessionRepo sessionRepo.Repository,
+	personalRepo personal_token.Repository,
+	dpopRepo dpop.Repository,
+	loginFailureRepo login_failure.Repository,
+	timeAdapter timeAdpt.Adapter,
+) Service {
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+
+	return &service{
+		log:              log.Named("cleanup"),
+		batchSize:        batchSize,
+		txRepo:           txRepo,
+		jwtRepo:          jwtRepo,
+		verificationRepo: verificationRepo,
+		resetRepo:        resetRepo,
+		sessionRepo:      sessionRepo,
+		personalRepo:     personalRepo,
+		dpopRepo:         dpopRepo,
+		loginFailureRepo: loginFailureRepo,
+		timeAdapter:      timeAdapter,
+	}
+}
+
+// DropExpired чистит все таблицы токенов. verification и reset токены хранят время в секундах, остальные в миллисекундах
+func (s *service) DropExpired(ctx context.Context) *errors.Error {
+	now := s.timeAdapter.Now()
+	nowMilli, nowSec := now.UnixNano()/1e+6, now.Unix()
+
+	tables := []struct {
+		name   string
+		delete batchDelete
+	}{
+		{"tokens", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.jwtRepo.DropExpiredTokensTX(ctx, tx, nowMilli, limit)
+		}},
+		{"rotated_refresh_tokens", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.jwtRepo.DropExpiredRotatedTokensTX(ctx, tx, nowMilli, limit)
+		}},
+		{"verification_tokens", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.verificationRepo.DeleteExpired(ctx, tx, nowSec, limit)
+		}},
+		{"reset_tokens", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.resetRepo.DeleteExpired(ctx, tx, nowSec, limit)
+		}},
+		{"personal_access_tokens", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.personalRepo.DropExpiredTX(ctx, tx, nowMilli, limit)
+		}},
+		{"dpop_proofs", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.dpopRepo.DropExpiredTX(ctx, tx, nowMilli, limit)
+		}},
+		{"login_failures", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.loginFailureRepo.DropExpiredTX(ctx, tx, nowMilli, limit)
+		}},
+		// сессии чистятся последними, после того как удалены их токены
+		{"sessions", func(ctx context.Context, tx transactions.Transaction, limit int) (int64, error) {
+			return s.sessionRepo.DropOrphanedTX(ctx, tx, limit)
+		}},
+	}
+
+	for _, table := range tables {
+		removed, err := s.dropInBatches(ctx, table.delete)
+		if err != nil {
+			return err
+		}
+		s.log.Info("expired rows removed", zap.String("table", table.name), zap.Int64("rows", removed))
+	}
+
+	return nil
+}
+
+// dropInBatches каждая пачка удаляется в своей транзакции, чтобы не держать долгие блокировки
+func (s *service) dropInBatches(ctx context.Context, del batchDelete) (int64, *errors.Error) {
+	var total int64
+	for {
+		if ctx.Err() != nil {
+			return total, nil
+		}
+
+		removed, err := s.dropBatch(ctx, del)
+		if err != nil {
+			return total, err
+		}
+
+		total += removed
+		if removed < int64(s.batchSize) {
+			return total, nil
+		}
+	}
+}
+
+func (s *service) dropBatch(ctx context.Context, del batchDelete) (int64, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return 0, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	removed, err := del(ctx, tx, s.batchSize)
+	if err != nil {
+		return 0, s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, s.log.ServiceTxError(err)
+	}
+
+	return removed, nil
+}
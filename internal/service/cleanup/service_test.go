@@ -0,0 +1,127 @@
+package cleanup
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	randomAdpt "github.com/warehouse/auth-service/internal/adapter/random"
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
+	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/reset_token"
+	sessionRepo "github.com/warehouse/auth-service/internal/repository/operations/session"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+	"github.com/warehouse/auth-service/internal/repository/operations/verification_token"
+	jwtSvc "github.com/warehouse/auth-service/internal/service/jwt"
+
+	"go.uber.org/zap"
+)
+
+const testAudience = "warehouse"
+
+// clock время, которое тест двигает сам
+type clock struct {
+	timeAdpt.Adapter
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+// TestLoginAfterCleanupGetsFreeNumber чистка удаляет access строку раньше refresh, номер сессии при этом остается занят
+func TestLoginAfterCleanupGetsFreeNumber(t *testing.T) {
+	ctx := context.Background()
+	log := logger.NewLogger(zap.NewNop())
+	client := db.NewMemoryClient()
+	txRepo := transactions.NewMemoryRepository(client)
+	tokens := jwtRepo.NewMemoryRepository(log, client)
+	sessions := sessionRepo.NewMemoryRepository(log, client)
+	personal := personal_token.NewMemoryRepository(log, client)
+	dpopProofs := dpop.NewMemoryRepository(log, client)
+	c := &clock{Adapter: timeAdpt.NewAdapter(3), now: time.Now()}
+
+	jwtService, err := jwtSvc.NewService(
+		log,
+		txRepo,
+		tokens,
+		sessions,
+		personal,
+		dpopProofs,
+		config.Auth{
+			Keys:                []config.JwtKey{{Id: "test", SigningMethod: "HS256", Key: "testSecret"}},
+			ActiveKey:           "test",
+			Issuer:              "warehouse-auth",
+			Audience:            []string{testAudience},
+			ServiceAudience:     testAudience,
+			AccessTokenTimeout:  time.Minute,
+			RefreshTokenTimeout: time.Hour,
+		},
+		c,
+		randomAdpt.NewAdapter(),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cleanup := NewService(
+		log,
+		config.Cleanup{},
+		txRepo,
+		tokens,
+		verification_token.NewMemoryRepository(log, client),
+		reset_token.NewMemoryRepository(log, client),
+		sessions,
+		personal,
+		dpopProofs,
+		login_failure.NewMemoryRepository(log, client),
+		c,
+	)
+
+	_, oldRefresh, e := jwtService.CreateTokens(ctx, domain.RoleUser, "user", domain.SessionMeta{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	c.advance(2 * time.Minute)
+	if e = cleanup.DropExpired(ctx); e != nil {
+		t.Fatal(e)
+	}
+
+	newAccess, _, e := jwtService.CreateTokens(ctx, domain.RoleUser, "user", domain.SessionMeta{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	active, e := jwtService.Sessions(ctx, domain.RoleUser, "user")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if len(active) != 2 || active[0].Number == active[1].Number {
+		t.Fatalf("sessions = %+v, want two sessions with different numbers", active)
+	}
+
+	if _, _, _, e = jwtService.ReCreateTokens(ctx, oldRefresh.Token, ""); e != nil {
+		t.Fatalf("refresh of the old session: %v", e)
+	}
+	if _, _, e = jwtService.Auth(ctx, newAccess.Token, domain.PurposeAccess, testAudience); e != nil {
+		t.Fatalf("new session must survive a refresh of the old one: %v", e)
+	}
+}
@@ -0,0 +1,297 @@
+package client
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"strings"
+
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/pkg/utils/encode"
+	rep_converters "github.com/warehouse/auth-service/internal/repository/converters"
+	repModels "github.com/warehouse/auth-service/internal/repository/models"
+	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/service_client"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+	jwtSvc "github.com/warehouse/auth-service/internal/service/jwt"
+
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+)
+
+const secretLength = 32
+
+type (
+	Service interface {
+		Create(ctx context.Context, reqData models.CreateClientRequest) (domain.ServiceClient, string, *errors.Error)
+		List(ctx context.Context) ([]domain.ServiceClient, *errors.Error)
+		RotateSecret(ctx context.Context, id string) (string, *errors.Error)
+		Delete(ctx context.Context, id string) *errors.Error
+		Authenticate(ctx context.Context, id, secret string) (domain.ServiceClient, *errors.Error)
+		IssueToken(ctx context.Context, id, secret, scope string, meta domain.SessionMeta) (domain.JwtTokenInfo, []string, *errors.Error)
+		ExchangeToken(ctx context.Context, id, secret string, reqData models.TokenExchangeRequest) (domain.JwtTokenInfo, []string, *errors.Error)
+	}
+
+	service struct {
+		log logger.Logger
+
+		txRepo     transactions.Repository
+		clientRepo service_client.Repository
+		jwtRepo    jwtRepo.Repository
+
+		jwtService  jwtSvc.Service
+		timeAdapter timeAdpt.Adapter
+
+		// allowedScopes скоупы, которые админ может выдать сервисному аккаунту
+		allowedScopes []string
+	}
+)
+
+func NewService(
+	log logger.Logger,
+	cfg config.Auth,
+	txRepo transactions.Repository,
+	clientRepo service_client.Repository,
+	jwtRepo jwtRepo.Repository,
+	jwtService jwtSvc.Service,
+	timeAdapter timeAdpt.Adapter,
+) Service {
+	return &service{
+		log:           log.Named("service_clients"),
+		txRepo:        txRepo,
+		clientRepo:    clientRepo,
+		jwtRepo:       jwtRepo,
+		jwtService:    jwtService,
+		timeAdapter:   timeAdapter,
+		allowedScopes: cfg.Scopes[int64(domain.RoleService)],
+	}
+}
+
+// Create возвращает секрет в открытом виде. Он показывается один раз, в базе лежит только хеш
+func (s *service) Create(ctx context.Context, reqData models.CreateClientRequest) (domain.ServiceClient, string, *errors.Error) {
+	if reqData.Name == "" {
+		return domain.ServiceClient{}, "", errors.WD(errors.ValidationFailed, errors.New("name is required"))
+	}
+
+	scopes := strings.Fields(reqData.Scope)
+	for _, scope := range scopes {
+		if !domain.HasScope(s.allowedScopes, scope) {
+			return domain.ServiceClient{}, "", errors.WD(errors.AuthInvalidScope, fmt.Errorf("scope %q is not allowed for service clients", scope))
+		}
+	}
+
+	secret, err := encode.RandomToken(secretLength)
+	if err != nil {
+		return domain.ServiceClient{}, "", s.log.ServiceError(errors.WD(errors.InternalError, err))
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.ServiceClient{}, "", s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	client := repModels.ServiceClient{
+		Id:         xid.New().String(),
+		Name:       reqData.Name,
+		SecretHash: encode.HashToken(secret),
+		Scope:      strings.Join(scopes, " "),
+		CreatedAt:  s.timeAdapter.Now().UnixNano() / 1e+6,
+	}
+	if err = s.clientRepo.Create(ctx, tx, client); err != nil {
+		return domain.ServiceClient{}, "", s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.ServiceClient{}, "", s.log.ServiceTxError(err)
+	}
+
+	return rep_converters.ModelServiceClient2DomainServiceClient(client), secret, nil
+}
+
+func (s *service) List(ctx context.Context) ([]domain.ServiceClient, *errors.Error) {
+	tx, err := s.txRepo.StartReadOnlyTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	clients, err := s.clientRepo.List(ctx, tx)
+	if err != nil {
+		return nil, s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+
+	res := make([]domain.ServiceClient, 0, len(clients))
+	for _, client := range clients {
+		res = append(res, rep_converters.ModelServiceClient2DomainServiceClient(client))
+	}
+
+	return res, nil
+}
+
+// RotateSecret выдает новый секрет. Уже выпущенные токены клиента отзываются
+func (s *service) RotateSecret(ctx context.Context, id string) (string, *errors.Error) {
+	secret, err := encode.RandomToken(secretLength)
+	if err != nil {
+		return "", s.log.ServiceError(errors.WD(errors.InternalError, err))
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return "", s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if err = s.clientRepo.UpdateSecret(ctx, tx, id, encode.HashToken(secret)); err != nil {
+		if err == errors.ServiceClientDoesNotExist {
+			return "", errors.AuthClientNotFound
+		}
+		return "", s.log.ServiceDatabaseError(err)
+	}
+
+	if err = s.jwtRepo.DropAllTokensTX(ctx, tx, domain.RoleService, id); err != nil {
+		return "", s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", s.log.ServiceTxError(err)
+	}
+
+	return secret, nil
+}
+
+func (s *service) Delete(ctx context.Context, id string) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if err = s.clientRepo.DeleteById(ctx, tx, id); err != nil {
+		if err == errors.ServiceClientDoesNotExist {
+			return errors.AuthClientNotFound
+		}
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err = s.jwtRepo.DropAllTokensTX(ctx, tx, domain.RoleService, id); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
+
+func (s *service) Authenticate(ctx context.Context, id, secret string) (domain.ServiceClient, *errors.Error) {
+	tx, err := s.txRepo.StartReadOnlyTransaction(ctx)
+	if err != nil {
+		return domain.ServiceClient{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	client, e := s.authenticateTX(ctx, tx, id, secret)
+	if e != nil {
+		return domain.ServiceClient{}, e
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.ServiceClient{}, s.log.ServiceTxError(err)
+	}
+
+	return rep_converters.ModelServiceClient2DomainServiceClient(client), nil
+}
+
+// IssueToken client_credentials grant. Запрошенный scope может только сузить скоупы клиента
+func (s *service) IssueToken(
+	ctx context.Context, id, secret, scope string, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, []string, *errors.Error) {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	client, e := s.authenticateTX(ctx, tx, id, secret)
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, e
+	}
+
+	granted := strings.Fields(client.Scope)
+	scopes := granted
+	if requested := strings.Fields(scope); len(requested) > 0 {
+		for _, scope := range requested {
+			if !domain.HasScope(granted, scope) {
+				return domain.JwtTokenInfo{}, nil, errors.WD(errors.AuthInvalidScope, fmt.Errorf("scope %q is not granted to client", scope))
+			}
+		}
+		scopes = requested
+	}
+
+	token, e := s.jwtService.CreateServiceTokenTX(ctx, tx, client.Id, scopes, meta)
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, e
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceTxError(err)
+	}
+
+	s.log.Info("service token issued", zap.String("client_id", client.Id))
+	return token, scopes, nil
+}
+
+// ExchangeToken token exchange (RFC 8693) для сервиса, который ходит в другой сервис от имени пользователя
+func (s *service) ExchangeToken(
+	ctx context.Context, id, secret string, reqData models.TokenExchangeRequest,
+) (domain.JwtTokenInfo, []string, *errors.Error) {
+	if reqData.SubjectToken == "" || reqData.Audience == "" {
+		return domain.JwtTokenInfo{}, nil, errors.WD(errors.ValidationFailed, errors.New("subject_token and audience are required"))
+	}
+
+	client, e := s.Authenticate(ctx, id, secret)
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, e
+	}
+
+	token, scopes, e := s.jwtService.ExchangeToken(ctx, reqData.SubjectToken, client.Id, reqData.Audience, strings.Fields(reqData.Scope))
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, e
+	}
+
+	s.log.Info("token exchanged", zap.String("client_id", client.Id), zap.String("audience", reqData.Audience))
+	return token, scopes, nil
+}
+
+func (s *service) authenticateTX(
+	ctx context.Context, tx transactions.Transaction, id, secret string,
+) (repModels.ServiceClient, *errors.Error) {
+	if _, err := xid.FromString(id); err != nil || secret == "" {
+		return repModels.ServiceClient{}, errors.AuthInvalidClient
+	}
+
+	client, err := s.clientRepo.GetById(ctx, tx, id)
+	if err != nil {
+		if err == errors.ServiceClientDoesNotExist {
+			return repModels.ServiceClient{}, errors.AuthInvalidClient
+		}
+		return repModels.ServiceClient{}, s.log.ServiceDatabaseError(err)
+	}
+
+	if subtle.ConstantTimeCompare([]byte(encode.HashToken(secret)), []byte(client.SecretHash)) != 1 {
+		return repModels.ServiceClient{}, errors.AuthInvalidClient
+	}
+
+	return client, nil
+}
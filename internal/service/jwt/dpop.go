@@ -0,0 +1,271 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/url"
+	"time"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	dpopProofType            = "dpop+jwt"
+	defaultDPoPProofLifetime = time.Minute
+	// dpopMinRsaBits более короткие RSA ключи считаются взломываемыми
+	dpopMinRsaBits = 2048
+)
+
+// VerifyDPoPProof проверяет DPoP proof (RFC 9449) и возвращает отпечаток ключа клиента.
+// Каждый jti принимается один раз, поэтому перехваченный proof повторить нельзя
+func (s *service) VerifyDPoPProof(ctx context.Context, proof string, req domain.DPoPRequest) (string, *errors.Error) {
+	jwk, claims, err := s.parseDPoPProof(proof)
+	if err != nil {
+		return "", s.log.ServiceError(errors.WD(errors.AuthInvalidDPoPProof, err))
+	}
+
+	if err = s.checkDPoPClaims(claims, req); err != nil {
+		return "", s.log.ServiceError(errors.WD(errors.AuthInvalidDPoPProof, err))
+	}
+
+	jkt, err := jwkThumbprint(jwk)
+	if err != nil {
+		return "", s.log.ServiceError(errors.WD(errors.AuthInvalidDPoPProof, err))
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return "", s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	jti, _ := claims["jti"].(string)
+	// jti нужно помнить, пока proof с таким iat еще проходит проверку
+	expiresAt := s.timeAdapter.Now().Add(2*s.dpopProofLifetime).UnixNano() / 1e+6
+	if err = s.dpopRepo.AddProofTX(ctx, tx, jkt, jti, expiresAt); err != nil {
+		if err == errors.DPoPProofReplayed {
+			return "", s.log.ServiceError(errors.WD(errors.AuthInvalidDPoPProof, err))
+		}
+		return "", s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return "", s.log.ServiceTxError(err)
+	}
+
+	return jkt, nil
+}
+
+// CheckDPoPBinding для токена с cnf.jkt проверяет proof запроса. Bearer токены пропускает без проверки
+func (s *service) CheckDPoPBinding(
+	ctx context.Context, claims domain.TokenClaims, proof string, req domain.DPoPRequest,
+) *errors.Error {
+	if claims.Jkt == "" {
+		return nil
+	}
+	if proof == "" {
+		return s.log.ServiceError(errors.AuthDPoPProofRequired)
+	}
+
+	jkt, err := s.VerifyDPoPProof(ctx, proof, req)
+	if err != nil {
+		return err
+	}
+
+	if err = checkDPoPBinding(claims.Jkt, jkt); err != nil {
+		return s.log.ServiceError(err)
+	}
+	return nil
+}
+
+// checkDPoPBinding токен с cnf.jkt принимается только с proof того же ключа
+func checkDPoPBinding(tokenJkt, proofJkt string) *errors.Error {
+	if tokenJkt == "" {
+		return nil
+	}
+	if proofJkt == "" {
+		return errors.AuthDPoPProofRequired
+	}
+	if subtle.ConstantTimeCompare([]byte(tokenJkt), []byte(proofJkt)) != 1 {
+		return errors.AuthDPoPKeyMismatch
+	}
+	return nil
+}
+
+// parseDPoPProof proof подписан ключом из собственного заголовка jwk. Симметричные алгоритмы запрещены
+func (s *service) parseDPoPProof(proof string) (domain.JWK, jwt.MapClaims, error) {
+	var jwk domain.JWK
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	token, err := parser.Parse(proof, func(token *jwt.Token) (interface{}, error) {
+		if typ, _ := token.Header["typ"].(string); typ != dpopProofType {
+			return nil, fmt.Errorf("unexpected typ: %v", token.Header["typ"])
+		}
+
+		switch token.Method.(type) {
+		case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *signingMethodEdDSA:
+		default:
+			return nil, fmt.Errorf("unsupported dpop alg: %s", token.Method.Alg())
+		}
+
+		raw, err := json.Marshal(token.Header["jwk"])
+		if err != nil {
+			return nil, err
+		}
+		if err = json.Unmarshal(raw, &jwk); err != nil {
+			return nil, fmt.Errorf("parse jwk header: %w", err)
+		}
+
+		return jwkPublicKey(jwk)
+	})
+	if err != nil {
+		return domain.JWK{}, nil, err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return domain.JWK{}, nil, fmt.Errorf("unexpected claims type")
+	}
+
+	return jwk, claims, nil
+}
+
+func (s *service) checkDPoPClaims(claims jwt.MapClaims, req domain.DPoPRequest) error {
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return fmt.Errorf("jti is required")
+	}
+
+	if htm, _ := claims["htm"].(string); htm != req.Method {
+		return fmt.Errorf("htm %q does not match request method", htm)
+	}
+
+	htu, _ := claims["htu"].(string)
+	if !sameHttpUri(htu, req.Url) {
+		return fmt.Errorf("htu %q does not match request uri", htu)
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return fmt.Errorf("iat is required")
+	}
+	if diff := s.timeAdapter.Now().Sub(time.Unix(int64(iat), 0)); diff > s.dpopProofLifetime || diff < -s.dpopProofLifetime {
+		return fmt.Errorf("iat is out of allowed window")
+	}
+
+	// при обращении с access токеном proof должен быть выпущен именно для него
+	if req.AccessToken != "" {
+		hash := sha256.Sum256([]byte(req.AccessToken))
+		ath, _ := claims["ath"].(string)
+		if subtle.ConstantTimeCompare([]byte(ath), []byte(encodeJwkBytes(hash[:]))) != 1 {
+			return fmt.Errorf("ath does not match access token")
+		}
+	}
+
+	return nil
+}
+
+// sameHttpUri htu сравнивается без query и fragment (RFC 9449, раздел 4.3)
+func sameHttpUri(htu, expected string) bool {
+	a, err := url.Parse(htu)
+	if err != nil || a.Scheme == "" || a.Host == "" {
+		return false
+	}
+	b, err := url.Parse(expected)
+	if err != nil {
+		return false
+	}
+
+	return a.Scheme == b.Scheme && a.Host == b.Host && a.Path == b.Path
+}
+
+func jwkPublicKey(jwk domain.JWK) (interface{}, error) {
+	switch jwk.Kty {
+	case "RSA":
+		n, err := decodeJwkInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+		if n.BitLen() < dpopMinRsaBits {
+			return nil, fmt.Errorf("rsa key must be at least %d bits", dpopMinRsaBits)
+		}
+		e, err := decodeJwkInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := decodeJwkInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeJwkInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("ec point is not on curve")
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+
+	case "OKP":
+		if jwk.Crv != "Ed25519" {
+			return nil, fmt.Errorf("unsupported curve: %s", jwk.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(jwk.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	}
+
+	return nil, fmt.Errorf("unsupported kty: %s", jwk.Kty)
+}
+
+// jwkThumbprint отпечаток ключа по RFC 7638: sha256 от обязательных полей в лексикографическом порядке
+func jwkThumbprint(jwk domain.JWK) (string, error) {
+	var canonical string
+	switch jwk.Kty {
+	case "RSA":
+		canonical = fmt.Sprintf(`{"e":%q,"kty":"RSA","n":%q}`, jwk.E, jwk.N)
+	case "EC":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"EC","x":%q,"y":%q}`, jwk.Crv, jwk.X, jwk.Y)
+	case "OKP":
+		canonical = fmt.Sprintf(`{"crv":%q,"kty":"OKP","x":%q}`, jwk.Crv, jwk.X)
+	default:
+		return "", fmt.Errorf("unsupported kty: %s", jwk.Kty)
+	}
+
+	hash := sha256.Sum256([]byte(canonical))
+	return encodeJwkBytes(hash[:]), nil
+}
+
+func decodeJwkInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil || len(b) == 0 {
+		return nil, fmt.Errorf("invalid jwk value")
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,83 @@
+package jwt
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"testing"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/rs/xid"
+)
+
+func signDPoPProof(t *testing.T, key ed25519.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(SigningMethodEdDSA, claims)
+	token.Header["typ"] = dpopProofType
+	token.Header["jwk"] = domain.JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   encodeJwkBytes(key.Public().(ed25519.PublicKey)),
+	}
+
+	proof, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return proof
+}
+
+func TestVerifyDPoPProofRejectsReplayedJti(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryService(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := domain.DPoPRequest{Method: "POST", Url: "https://auth.example.com/api/v1/auth/login"}
+	proof := signDPoPProof(t, key, jwt.MapClaims{
+		"jti": xid.New().String(),
+		"htm": req.Method,
+		"htu": req.Url,
+		"iat": s.(*service).timeAdapter.Now().Unix(),
+	})
+
+	jkt, e := s.VerifyDPoPProof(ctx, proof, req)
+	if e != nil {
+		t.Fatal(e)
+	}
+	if jkt == "" {
+		t.Fatal("thumbprint of the proof key is empty")
+	}
+
+	_, e = s.VerifyDPoPProof(ctx, proof, req)
+	if e == nil || e.Details != errors.DPoPProofReplayed {
+		t.Fatalf("replay err = %v, want %v with %v", e, errors.AuthInvalidDPoPProof, errors.DPoPProofReplayed)
+	}
+}
+
+func TestVerifyDPoPProofChecksRequest(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryService(t)
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proof := signDPoPProof(t, key, jwt.MapClaims{
+		"jti": xid.New().String(),
+		"htm": "POST",
+		"htu": "https://auth.example.com/api/v1/auth/login",
+		"iat": s.(*service).timeAdapter.Now().Unix(),
+	})
+
+	_, e := s.VerifyDPoPProof(ctx, proof, domain.DPoPRequest{Method: "POST", Url: "https://auth.example.com/api/v1/auth/refresh"})
+	if e == nil || e.Code != errors.AuthInvalidDPoPProof.Code || e.Reason != errors.AuthInvalidDPoPProof.Reason {
+		t.Fatalf("err = %v, want %v for a proof issued to another uri", e, errors.AuthInvalidDPoPProof)
+	}
+}
@@ -0,0 +1,86 @@
+package jwt
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+)
+
+// ExchangeToken RFC 8693: меняет access токен пользователя на токен для одной аудитории с суженными скоупами.
+// Новый токен ссылается на секрет исходного, поэтому отзыв или ротация сессии пользователя отзывает и его,
+// а срок жизни не превышает срок исходного токена. В act записывается сервис, который обменял токен
+func (s *service) ExchangeToken(
+	ctx context.Context, subjectToken, actorId, audience string, scopes []string,
+) (domain.JwtTokenInfo, []string, *errors.Error) {
+	if !slices.Contains(s.audience, audience) {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(errors.WD(errors.AuthInvalidTarget, fmt.Errorf("unknown audience %q", audience)))
+	}
+
+	t, err := s.parseToken(subjectToken)
+	if err != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(invalidGrant(err))
+	}
+
+	claims, secret, err := s.verifyClaims(t, domain.PurposeAccess, "")
+	if err != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(invalidGrant(err))
+	}
+	// повторная делегация и токены от чужого имени не обмениваются
+	if claims.Actor != "" || claims.Role.PrincipalType() != domain.PrincipalUser {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(errors.WD(errors.AuthInvalidGrant, fmt.Errorf("subject token must belong to a user")))
+	}
+
+	granted := claims.Scopes
+	if len(scopes) > 0 {
+		for _, scope := range scopes {
+			if !domain.HasScope(claims.Scopes, scope) {
+				return domain.JwtTokenInfo{}, nil, s.log.ServiceError(errors.WD(errors.AuthInvalidScope, fmt.Errorf("scope %q is not granted to subject token", scope)))
+			}
+		}
+		granted = scopes
+	}
+
+	tx, e := s.txRepo.StartReadOnlyTransaction(ctx)
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceTxError(e)
+	}
+	defer tx.Rollback()
+
+	if err = s.checkTokenSecret(ctx, tx, claims, secret); err != nil {
+		if err.Details == errors.TokenDoesNotExist {
+			return domain.JwtTokenInfo{}, nil, s.log.ServiceError(invalidGrant(err))
+		}
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(err)
+	}
+
+	if e = tx.Commit(); e != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceTxError(e)
+	}
+
+	expiresAt := minTime(s.timeAdapter.Now().Add(s.atTimeout), time.Unix(claims.ExpiresAt, 0))
+	extra := scopeClaims(granted)
+	extra["aud"] = []string{audience}
+	extra["act"] = map[string]interface{}{"sub": actorId}
+	// обмен не снимает привязку к DPoP ключу: иначе перехваченный привязанный токен можно было бы отвязать
+	if claims.Jkt != "" {
+		extra["cnf"] = map[string]interface{}{"jkt": claims.Jkt}
+	}
+
+	token, e := s.signToken(claims.Role, claims.UserId, claims.Number, domain.PurposeAccess, secret, expiresAt, extra)
+	if e != nil {
+		return domain.JwtTokenInfo{}, nil, s.log.ServiceError(errors.WD(errors.AuthCreateTokens, e))
+	}
+
+	return domain.JwtTokenInfo{
+		Token:     token,
+		ExpiresAt: expiresAt.UnixNano() / 1e+6,
+	}, granted, nil
+}
+
+func invalidGrant(err *errors.Error) *errors.Error {
+	return errors.WD(errors.AuthInvalidGrant, fmt.Errorf("subject token: %s", err.Reason))
+}
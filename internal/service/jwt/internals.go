@@ -8,86 +8,325 @@ import (
 	"strings"
 	"time"
 
+	"github.com/warehouse/auth-service/internal/config"
 	"github.com/warehouse/auth-service/internal/domain"
 	"github.com/warehouse/auth-service/internal/pkg/errors"
 	"github.com/warehouse/auth-service/internal/pkg/errors/service_errors"
+	rep_converters "github.com/warehouse/auth-service/internal/repository/converters"
 	"github.com/warehouse/auth-service/internal/repository/models"
 	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
 )
 
+func (s *service) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	key, err := s.keys.verification(kid, s.timeAdapter.Now())
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != key.method.Alg() {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return key.verifyKey, nil
+}
+
+// parseToken сроки жизни проверяет verifyClaims по timeAdapter, поэтому token.Valid говорит только о подписи
 func (s *service) parseToken(token string) (*jwt.Token, *errors.Error) {
-	res, err := jwt.Parse(token, func(token *jwt.Token) (i interface{}, e error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(s.jwtKey), nil
-	})
+	parser := jwt.Parser{SkipClaimsValidation: true}
+	res, err := parser.Parse(token, s.keyFunc)
 	if err != nil && res == nil {
 		return nil, s.log.ServiceError(errors.WD(errors.AuthParseToken, err))
 	}
 	return res, nil
 }
 
+// parseExpiredToken принимает просроченный токен, но только с валидной подписью
+func (s *service) parseExpiredToken(token string) (*jwt.Token, *errors.Error) {
+	res, err := jwt.Parse(token, s.keyFunc)
+	if err != nil {
+		validationErr, ok := err.(*jwt.ValidationError)
+		if !ok || res == nil || validationErr.Errors&^jwt.ValidationErrorExpired != 0 {
+			return nil, errors.WD(errors.AuthInvalidToken, err)
+		}
+	}
+	return res, nil
+}
+
 func (s *service) checkToken(
-	ctx context.Context, tx transactions.Transaction, token *jwt.Token, purpose domain.AuthPurpose,
-) (domain.Account, int64, *errors.Error) {
+	ctx context.Context, tx transactions.Transaction, token *jwt.Token, purpose domain.AuthPurpose, audience string,
+) (domain.Account, domain.TokenClaims, *errors.Error) {
+	tokenClaims, secret, err := s.verifyClaims(token, purpose, audience)
+	if err != nil {
+		return domain.Account{}, domain.TokenClaims{}, err
+	}
+
+	if err := s.checkTokenSecret(ctx, tx, tokenClaims, secret); err != nil {
+		return domain.Account{}, domain.TokenClaims{}, err
+	}
+
+	// TODO: добавить подтяг данных пользователя
+	return domain.Account{
+		Role: tokenClaims.Role,
+		Id:   tokenClaims.UserId,
+	}, tokenClaims, nil
+}
+
+// checkTokenSecret токен валиден, только пока его секрет лежит в базе
+func (s *service) checkTokenSecret(
+	ctx context.Context, tx transactions.Transaction, tokenClaims domain.TokenClaims, secret string,
+) *errors.Error {
+	tokenModel := models.Token{
+		UserId:  tokenClaims.UserId,
+		Number:  tokenClaims.Number,
+		Purpose: int(tokenClaims.Purpose),
+		Secret:  secret,
+	}
+	if _, e := s.repo.CheckTokenTX(ctx, tx, tokenClaims.Role, tokenModel); e != nil {
+		if e == errors.TokenDoesNotExist {
+			return errors.WD(errors.AuthInvalidToken, e)
+		}
+		return errors.WD(service_errors.DatabaseError, e)
+	}
+
+	return nil
+}
+
+// verifyClaims проверяет подпись и клеймы токена без похода в базу. Вторым значением возвращается секрет токена
+func (s *service) verifyClaims(
+	token *jwt.Token, purpose domain.AuthPurpose, audience string,
+) (domain.TokenClaims, string, *errors.Error) {
 	claims, ok := token.Claims.(jwt.MapClaims)
 	if !ok {
-		return domain.Account{}, 0, errors.AuthParseToken
+		return domain.TokenClaims{}, "", errors.AuthParseToken
 	}
 
-	if !claims.VerifyExpiresAt(s.timeAdapter.Now().Unix(), true) {
-		return domain.Account{}, 0, errors.AuthExpiredToken
+	// подпись первой: про поддельный токен нельзя отвечать, что он просрочен
+	if !token.Valid {
+		return domain.TokenClaims{}, "", errors.AuthInvalidToken
 	}
 
-	if realPurpose, ok := claims["purpose"].(float64); !ok {
-		return domain.Account{}, 0, errors.AuthInvalidToken
-	} else if domain.AuthPurpose(realPurpose) != purpose {
-		return domain.Account{}, 0, errors.AuthInvalidTokenPurpose
+	now := s.timeAdapter.Now().Unix()
+	if !claims.VerifyExpiresAt(now, true) {
+		return domain.TokenClaims{}, "", errors.AuthExpiredToken
 	}
 
-	if !token.Valid {
-		return domain.Account{}, 0, errors.AuthInvalidToken
+	// nbf и iat нет в токенах, выпущенных до их появления
+	if !claims.VerifyNotBefore(now, false) || !claims.VerifyIssuedAt(now, false) {
+		return domain.TokenClaims{}, "", errors.AuthTokenNotValidYet
+	}
+
+	tokenClaims, secret, err := s.parseClaims(claims)
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
+
+	if tokenClaims.Purpose != purpose {
+		return domain.TokenClaims{}, "", errors.AuthInvalidTokenPurpose
+	}
+
+	if !s.verifyAudience(tokenClaims.Audience, audience) {
+		return domain.TokenClaims{}, "", errors.AuthInvalidAudience
+	}
+
+	return tokenClaims, secret, nil
+}
+
+// parseClaims разбирает клеймы, выпущенные generateTokenHash. Срок жизни и аудиторию не проверяет.
+// Токены, выпущенные до появления iss, aud, jti, iat и nbf, принимаются без них до своего exp,
+// чтобы выкладка не разлогинила всех пользователей. Если клейм есть, он проверяется
+func (s *service) parseClaims(claims jwt.MapClaims) (domain.TokenClaims, string, *errors.Error) {
+	if issuer, ok := claims["iss"]; ok && issuer != s.issuer {
+		return domain.TokenClaims{}, "", errors.AuthInvalidTokenIssuer
 	}
 
+	var tokenAudience []string
+	if _, ok := claims["aud"]; ok {
+		var err *errors.Error
+		if tokenAudience, err = s.parseTokenAudienceClaim(claims); err != nil {
+			return domain.TokenClaims{}, "", err
+		}
+	}
+
+	jti, err := s.parseOptionalStringClaim(claims, "jti")
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
 	user_id, err := s.parseTokenStringClaim(claims, "user_id")
 	if err != nil {
-		return domain.Account{}, 0, err
+		return domain.TokenClaims{}, "", err
 	}
 	number, err := s.parseTokenIntClaim(claims, "number")
 	if err != nil {
-		return domain.Account{}, 0, err
+		return domain.TokenClaims{}, "", err
 	}
 	role, err := s.parseTokenIntClaim(claims, "role")
 	if err != nil {
-		return domain.Account{}, 0, err
+		return domain.TokenClaims{}, "", err
+	}
+	purpose, err := s.parseTokenIntClaim(claims, "purpose")
+	if err != nil {
+		return domain.TokenClaims{}, "", err
 	}
 	secret, err := s.parseTokenStringClaim(claims, "secret")
 	if err != nil {
-		return domain.Account{}, 0, err
+		return domain.TokenClaims{}, "", err
+	}
+	issuedAt, err := s.parseOptionalIntClaim(claims, "iat")
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
+	notBefore, err := s.parseOptionalIntClaim(claims, "nbf")
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
+	expiresAt, err := s.parseTokenIntClaim(claims, "exp")
+	if err != nil {
+		return domain.TokenClaims{}, "", err
 	}
 
-	if _, ok := s.repo.GetTokenMap()[domain.Role(role)]; !ok {
-		return domain.Account{}, 0, errors.AuthInvalidToken
+	actor, err := s.parseTokenActorClaim(claims)
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
+	jkt, err := s.parseTokenCnfClaim(claims)
+	if err != nil {
+		return domain.TokenClaims{}, "", err
+	}
+	scope, _ := claims["scope"].(string)
+	scopes := strings.Fields(scope)
+	if _, ok := claims["iss"]; !ok {
+		// токены старше скоупов получают все права роли, иначе они не пройдут проверку скоупов до своего exp
+		scopes = s.scopes[domain.Role(role)]
+	}
+	authTime, _ := claims["auth_time"].(float64)
+	amr, err := s.parseTokenAmrClaim(claims)
+	if err != nil {
+		return domain.TokenClaims{}, "", err
 	}
 
-	tokenModel := models.Token{
-		UserId:  user_id,
-		Number:  number,
-		Purpose: int(purpose),
-		Secret:  secret,
+	return domain.TokenClaims{
+		Id:        jti,
+		Issuer:    s.issuer,
+		Audience:  tokenAudience,
+		UserId:    user_id,
+		Role:      domain.Role(role),
+		Purpose:   domain.AuthPurpose(purpose),
+		Number:    number,
+		IssuedAt:  issuedAt,
+		NotBefore: notBefore,
+		ExpiresAt: expiresAt,
+		Actor:     actor,
+		Scopes:    scopes,
+		Jkt:       jkt,
+		AuthTime:  int64(authTime),
+		Amr:       amr,
+	}, secret, nil
+}
+
+// parseTokenActorClaim act по RFC 8693 - объект, в котором sub это тот, кто действует от имени пользователя
+func (s *service) parseTokenActorClaim(claims jwt.MapClaims) (string, *errors.Error) {
+	act, ok := claims["act"]
+	if !ok {
+		return "", nil
 	}
-	if _, e := s.repo.CheckTokenTX(ctx, tx, domain.Role(role), tokenModel); e != nil {
-		return domain.Account{}, 0, errors.WD(service_errors.DatabaseError, e)
+
+	actClaims, ok := act.(map[string]interface{})
+	if !ok {
+		return "", errors.AuthInvalidToken
+	}
+	sub, ok := actClaims["sub"].(string)
+	if !ok || sub == "" {
+		return "", errors.AuthInvalidToken
 	}
 
-	// TODO: добавить подтяг данных пользователя
-	return domain.Account{
-		Role: domain.Role(role),
-		Id:   user_id,
-	}, number, nil
+	return sub, nil
+}
+
+// parseTokenCnfClaim отпечаток DPoP ключа из cnf.jkt (RFC 9449). Пусто для bearer токенов
+func (s *service) parseTokenCnfClaim(claims jwt.MapClaims) (string, *errors.Error) {
+	cnf, ok := claims["cnf"]
+	if !ok {
+		return "", nil
+	}
+
+	cnfClaims, ok := cnf.(map[string]interface{})
+	if !ok {
+		return "", errors.AuthInvalidToken
+	}
+	jkt, ok := cnfClaims["jkt"].(string)
+	if !ok || jkt == "" {
+		return "", errors.AuthInvalidToken
+	}
+
+	return jkt, nil
+}
+
+func (s *service) parseTokenAmrClaim(claims jwt.MapClaims) ([]string, *errors.Error) {
+	raw, ok := claims["amr"]
+	if !ok {
+		return nil, nil
+	}
+
+	values, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.AuthInvalidToken
+	}
+	amr := make([]string, 0, len(values))
+	for _, value := range values {
+		method, ok := value.(string)
+		if !ok {
+			return nil, errors.AuthInvalidToken
+		}
+		amr = append(amr, method)
+	}
+
+	return amr, nil
+}
+
+// parseTokenAudienceClaim aud по RFC 7519 может быть как строкой, так и массивом строк
+func (s *service) parseTokenAudienceClaim(claims jwt.MapClaims) ([]string, *errors.Error) {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}, nil
+	case []interface{}:
+		res := make([]string, 0, len(aud))
+		for _, a := range aud {
+			value, ok := a.(string)
+			if !ok {
+				return nil, errors.AuthInvalidToken
+			}
+			res = append(res, value)
+		}
+		return res, nil
+	default:
+		return nil, errors.AuthInvalidToken
+	}
+}
+
+// verifyAudience без явной аудитории токен должен быть выпущен хотя бы для одной из аудиторий из конфига.
+// Токен без aud выпущен до появления аудиторий и проходит
+func (s *service) verifyAudience(tokenAudience []string, expected string) bool {
+	if tokenAudience == nil {
+		return true
+	}
+
+	allowed := s.audience
+	if expected != "" {
+		allowed = []string{expected}
+	}
+
+	for _, a := range tokenAudience {
+		for _, b := range allowed {
+			if a == b {
+				return true
+			}
+		}
+	}
+
+	return false
 }
 
 func (s *service) parseTokenIntClaim(claims jwt.MapClaims, key string) (int64, *errors.Error) {
@@ -98,6 +337,21 @@ func (s *service) parseTokenIntClaim(claims jwt.MapClaims, key string) (int64, *
 	}
 }
 
+// parseOptionalIntClaim отсутствующий клейм - ноль, клейм другого типа - ошибка
+func (s *service) parseOptionalIntClaim(claims jwt.MapClaims, key string) (int64, *errors.Error) {
+	if _, ok := claims[key]; !ok {
+		return 0, nil
+	}
+	return s.parseTokenIntClaim(claims, key)
+}
+
+func (s *service) parseOptionalStringClaim(claims jwt.MapClaims, key string) (string, *errors.Error) {
+	if _, ok := claims[key]; !ok {
+		return "", nil
+	}
+	return s.parseTokenStringClaim(claims, key)
+}
+
 func (s *service) parseTokenStringClaim(claims jwt.MapClaims, key string) (string, *errors.Error) {
 	if stringValue, ok := claims[key].(string); !ok {
 		return "", errors.AuthInvalidToken
@@ -107,24 +361,185 @@ func (s *service) parseTokenStringClaim(claims jwt.MapClaims, key string) (strin
 }
 
 func (s *service) createTokens(
-	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string,
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, meta domain.SessionMeta,
 ) (int64, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
-	now := s.timeAdapter.Now()
+	if e := s.validateScopes(role, meta.Scopes); e != nil {
+		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, e
+	}
+
+	if err := s.repo.LockUserTX(ctx, tx, role, userId); err != nil {
+		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.DatabaseError(err)
+	}
+
+	if e := s.enforceSessionLimit(ctx, tx, role, userId); e != nil {
+		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, e
+	}
 
 	number, err := s.repo.FindNumberTX(ctx, tx, role, userId)
 	if err != nil {
 		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.DatabaseError(err)
 	}
+
+	now := s.timeAdapter.Now().UnixNano() / 1e+6
+	session := domain.Session{
+		Role:       role,
+		UserId:     userId,
+		Number:     number,
+		Ip:         meta.Ip,
+		UserAgent:  meta.UserAgent,
+		CreatedAt:  now,
+		LastUsedAt: now,
+		Scopes:     meta.Scopes,
+		Jkt:        meta.Jkt,
+		AuthTime:   now,
+		Amr:        meta.Amr,
+	}
+	if err = s.sessionRepo.UpsertTX(ctx, tx, rep_converters.DomainSession2ModelSession(session)); err != nil {
+		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.DatabaseError(err)
+	}
+
+	// новая сессия открывает новое семейство refresh токенов
+	accessToken, refreshToken, e := s.issueTokens(ctx, tx, session, xid.New().String())
+	if e != nil {
+		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, e
+	}
+
+	return number, accessToken, refreshToken, nil
+}
+
+// issueAccessOnlyTX отдельная сессия с одним access токеном без refresh
+func (s *service) issueAccessOnlyTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, meta domain.SessionMeta,
+	ttl time.Duration, claims jwt.MapClaims,
+) (domain.JwtTokenInfo, int64, *errors.Error) {
+	if err := s.repo.LockUserTX(ctx, tx, role, userId); err != nil {
+		return domain.JwtTokenInfo{}, 0, errors.DatabaseError(err)
+	}
+
+	// сессия имперсонации не должна вытеснять сессии пользователя, а сервисные сессии без лимита копились бы на каждый вызов
+	if meta.ActorId == "" {
+		if e := s.enforceSessionLimit(ctx, tx, role, userId); e != nil {
+			return domain.JwtTokenInfo{}, 0, e
+		}
+	}
+
+	number, err := s.repo.FindNumberTX(ctx, tx, role, userId)
+	if err != nil {
+		return domain.JwtTokenInfo{}, 0, errors.DatabaseError(err)
+	}
+
+	now := s.timeAdapter.Now()
+	session := domain.Session{
+		Role:       role,
+		UserId:     userId,
+		Number:     number,
+		Ip:         meta.Ip,
+		UserAgent:  meta.UserAgent,
+		CreatedAt:  now.UnixNano() / 1e+6,
+		LastUsedAt: now.UnixNano() / 1e+6,
+		Scopes:     meta.Scopes,
+		ActorId:    meta.ActorId,
+	}
+	if err = s.sessionRepo.UpsertTX(ctx, tx, rep_converters.DomainSession2ModelSession(session)); err != nil {
+		return domain.JwtTokenInfo{}, 0, errors.DatabaseError(err)
+	}
+
+	expiresAt := now.Add(ttl)
+	token, err := s.generateTokenHash(ctx, tx, role, userId, number, xid.New().String(), domain.PurposeAccess, expiresAt, claims)
+	if err != nil {
+		return domain.JwtTokenInfo{}, 0, errors.DatabaseError(err)
+	}
+
+	return domain.JwtTokenInfo{
+		Token:     token,
+		ExpiresAt: expiresAt.UnixNano() / 1e+6,
+	}, number, nil
+}
+
+// enforceSessionLimit освобождает место под новую сессию по политике роли:
+// reject отказывает и перечисляет активные сессии, evict_lru закрывает давно не использованные
+func (s *service) enforceSessionLimit(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) *errors.Error {
+	limit, ok := s.sessionLimits[role]
+	if !ok || limit.Max == 0 {
+		return nil
+	}
+
+	active, err := s.sessionRepo.GetActiveTX(ctx, tx, role, userId, s.timeAdapter.Now().UnixNano()/1e+6)
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	// сессии имперсонации открывает админ, пользователя они из лимита не вытесняют
+	sessions := active[:0]
+	for _, session := range active {
+		if session.ActorId == "" {
+			sessions = append(sessions, session)
+		}
+	}
+	if len(sessions) < limit.Max {
+		return nil
+	}
+
+	if limit.Policy == config.SessionPolicyReject {
+		payload := make([]domain.Session, 0, len(sessions))
+		for _, session := range sessions {
+			payload = append(payload, rep_converters.ModelSession2DomainSession(session))
+		}
+		return errors.WP(errors.AuthSessionLimitReached, payload)
+	}
+
+	// сессии отсортированы по last_used_at от новых к старым
+	for _, session := range sessions[limit.Max-1:] {
+		if err = s.repo.DropTokensTX(ctx, tx, role, userId, session.Number); err != nil {
+			return errors.DatabaseError(err)
+		}
+		s.log.Zap().Info("session evicted",
+			zap.Int64("role", int64(role)),
+			zap.String("user_id", userId),
+			zap.Int64("number", session.Number),
+		)
+	}
+
+	return nil
+}
+
+// issueTokens срок жизни токенов не выходит за idle таймаут и максимальный возраст сессии
+func (s *service) issueTokens(
+	ctx context.Context, tx transactions.Transaction, session domain.Session, family string,
+) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
+	role, userId, number := session.Role, session.UserId, session.Number
+	sessionStart := time.UnixMilli(session.CreatedAt)
+	extra := scopeClaims(s.sessionScopes(role, session.Scopes))
+	if session.Jkt != "" {
+		extra["cnf"] = map[string]interface{}{"jkt": session.Jkt}
+	}
+	// время входа переносится из сессии, поэтому рефреш не делает токен "свежим"
+	if session.AuthTime > 0 {
+		extra["auth_time"] = session.AuthTime / 1e+3
+		if len(session.Amr) > 0 {
+			extra["amr"] = session.Amr
+		}
+	}
+
+	now := s.timeAdapter.Now()
 	accessExpiresAt, refreshExpiresAt := now.Add(s.atTimeout), now.Add(s.rtTimeout)
+	if s.sessionIdleTimeout > 0 {
+		refreshExpiresAt = minTime(refreshExpiresAt, now.Add(s.sessionIdleTimeout))
+	}
+	if s.sessionMaxAge > 0 {
+		sessionEnd := sessionStart.Add(s.sessionMaxAge)
+		accessExpiresAt = minTime(accessExpiresAt, sessionEnd)
+		refreshExpiresAt = minTime(refreshExpiresAt, sessionEnd)
+	}
 
-	accessTokenHash, err := s.generateTokenHash(ctx, tx, role, userId, number, domain.PurposeAccess, accessExpiresAt)
+	accessTokenHash, err := s.generateTokenHash(ctx, tx, role, userId, number, family, domain.PurposeAccess, accessExpiresAt, extra)
 	if err != nil {
-		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.DatabaseError(err)
+		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, errors.DatabaseError(err)
 	}
 
-	refreshTokenHash, err := s.generateTokenHash(ctx, tx, role, userId, number, domain.PurposeRefresh, refreshExpiresAt)
+	refreshTokenHash, err := s.generateTokenHash(ctx, tx, role, userId, number, family, domain.PurposeRefresh, refreshExpiresAt, extra)
 	if err != nil {
-		return 0, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
 	}
 
 	accessToken := domain.JwtTokenInfo{
@@ -136,12 +551,95 @@ func (s *service) createTokens(
 		ExpiresAt: refreshExpiresAt.UnixNano() / 1e+6,
 	}
 
-	return number, accessToken, refreshToken, nil
+	return accessToken, refreshToken, nil
+}
+
+// checkSessionAlive сессия истекает, если ее долго не обновляли или она старше максимального возраста
+func (s *service) checkSessionAlive(
+	ctx context.Context, tx transactions.Transaction, claims domain.TokenClaims,
+) (models.Session, *errors.Error) {
+	session, err := s.sessionRepo.GetTX(ctx, tx, claims.Role, claims.UserId, claims.Number)
+	if err != nil {
+		if err == errors.SessionDoesNotExist {
+			return models.Session{}, errors.WD(errors.AuthInvalidToken, err)
+		}
+		return models.Session{}, errors.DatabaseError(err)
+	}
+
+	now := s.timeAdapter.Now()
+	if s.sessionIdleTimeout > 0 && now.Sub(time.UnixMilli(session.LastUsedAt)) > s.sessionIdleTimeout {
+		return models.Session{}, errors.AuthSessionExpired
+	}
+	if s.sessionMaxAge > 0 && now.Sub(time.UnixMilli(session.CreatedAt)) > s.sessionMaxAge {
+		return models.Session{}, errors.AuthSessionExpired
+	}
+
+	return session, nil
+}
+
+// rotateRefreshToken переносит текущий refresh токен сессии в историю семейства и удаляет пару токенов
+func (s *service) rotateRefreshToken(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64,
+) (string, *errors.Error) {
+	current, err := s.repo.GetTokenTX(ctx, tx, role, userId, number, domain.PurposeRefresh)
+	if err != nil {
+		return "", errors.DatabaseError(err)
+	}
+
+	rotated := models.RotatedToken{
+		Secret:    current.Secret,
+		FamilyId:  current.FamilyId,
+		Role:      int64(role),
+		UserId:    userId,
+		Number:    number,
+		RotatedAt: s.timeAdapter.Now().UnixNano() / 1e+6,
+		ExpiresAt: current.ExpiresAt,
+	}
+	if err := s.repo.AddRotatedTokenTX(ctx, tx, rotated); err != nil {
+		return "", errors.DatabaseError(err)
+	}
+
+	if err := s.repo.DropTokensTX(ctx, tx, role, userId, number); err != nil {
+		return "", errors.DatabaseError(err)
+	}
+
+	return current.FamilyId, nil
+}
+
+// revokeReusedFamily вызывается, когда предъявленного refresh токена нет среди живых.
+// Если он был обменян раньше, токен украден или утек: отзываем все семейство
+func (s *service) revokeReusedFamily(
+	ctx context.Context, tx transactions.Transaction, tokenClaims domain.TokenClaims, secret string,
+) *errors.Error {
+	rotated, err := s.repo.GetRotatedTokenTX(ctx, tx, secret)
+	if err == errors.TokenDoesNotExist {
+		return errors.AuthInvalidToken
+	}
+	if err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	if err := s.repo.DropFamilyTX(ctx, tx, tokenClaims.Role, rotated.FamilyId); err != nil {
+		return errors.DatabaseError(err)
+	}
+
+	s.log.Zap().Warn(
+		"security_event",
+		zap.String("event", "refresh_token_reuse"),
+		zap.String("user_id", tokenClaims.UserId),
+		zap.Int64("role", int64(tokenClaims.Role)),
+		zap.Int64("number", rotated.Number),
+		zap.String("family_id", rotated.FamilyId),
+		zap.Int64("rotated_at", rotated.RotatedAt),
+		zap.String("jti", tokenClaims.Id),
+	)
+
+	return errors.AuthRefreshTokenReused
 }
 
 func (s *service) generateTokenHash(
-	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, purpose domain.AuthPurpose,
-	expire time.Time,
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, number int64, family string,
+	purpose domain.AuthPurpose, expire time.Time, extra jwt.MapClaims,
 ) (string, error) {
 	secret := s.generateSecret(role, userId, number, purpose)
 	tokenToAdd := models.Token{
@@ -150,11 +648,26 @@ func (s *service) generateTokenHash(
 		Purpose:   int(purpose),
 		Secret:    secret,
 		ExpiresAt: expire.UnixNano() / 1e+6,
+		FamilyId:  family,
 	}
 	if _, e := s.repo.AddTokenTX(ctx, tx, role, tokenToAdd); e != nil {
 		return "", s.log.Error(e, service_errors.DatabaseErrorRaw)
 	}
+
+	return s.signToken(role, userId, number, purpose, secret, expire, extra)
+}
+
+// signToken подписывает токен с уже сохраненным секретом. extra дополняет и переопределяет стандартные клеймы
+func (s *service) signToken(
+	role domain.Role, userId string, number int64, purpose domain.AuthPurpose, secret string, expire time.Time, extra jwt.MapClaims,
+) (string, error) {
+	now := s.timeAdapter.Now()
 	claims := jwt.MapClaims{
+		"iss":     s.issuer,
+		"aud":     s.audience,
+		"iat":     now.Unix(),
+		"nbf":     now.Unix(),
+		"jti":     xid.New().String(),
 		"user_id": userId,
 		"role":    role,
 		"purpose": purpose,
@@ -162,8 +675,13 @@ func (s *service) generateTokenHash(
 		"exp":     expire.Unix(),
 		"number":  number,
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	res, e := token.SignedString([]byte(s.jwtKey))
+	for name, value := range extra {
+		claims[name] = value
+	}
+	key := s.keys.signing()
+	token := jwt.NewWithClaims(key.method, claims)
+	token.Header["kid"] = key.id
+	res, e := token.SignedString(key.signKey)
 	if e != nil {
 		return "", s.log.Error(e, errors.AuthParseTokenRaw)
 	}
@@ -173,7 +691,7 @@ func (s *service) generateTokenHash(
 func (s *service) generateSecret(role domain.Role, userId string, number int64, purpose domain.AuthPurpose) string {
 	toHashElems := []string{
 		fmt.Sprintf("%d", role),
-		fmt.Sprintf("%d", userId),
+		userId,
 		fmt.Sprintf("%d", number),
 		fmt.Sprintf("%d", purpose),
 		fmt.Sprintf("%d", s.timeAdapter.Now().UnixNano()),
@@ -183,3 +701,10 @@ func (s *service) generateSecret(role domain.Role, userId string, number int64,
 	hash := sha256.Sum256([]byte(toHash))
 	return hex.EncodeToString(hash[:])
 }
+
+func minTime(a, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,215 @@
+package jwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/domain"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const defaultSigningMethod = "HS256"
+
+type (
+	signingKey struct {
+		id        string
+		method    jwt.SigningMethod
+		signKey   interface{}
+		verifyKey interface{}
+		retireAt  time.Time
+	}
+
+	// keyring активный ключ подписывает новые токены, остальные только проверяют старые до retireAt
+	keyring struct {
+		lock   sync.RWMutex
+		active *signingKey
+		keys   map[string]*signingKey
+	}
+)
+
+func newKeyring(cfg config.Auth) (*keyring, error) {
+	k := &keyring{}
+	if err := k.replace(cfg.Keys, cfg.ActiveKey); err != nil {
+		return nil, err
+	}
+
+	return k, nil
+}
+
+func (k *keyring) replace(cfgKeys []config.JwtKey, activeId string) error {
+	keys := make(map[string]*signingKey, len(cfgKeys))
+	for _, cfgKey := range cfgKeys {
+		if _, ok := keys[cfgKey.Id]; ok {
+			return fmt.Errorf("duplicate jwt key id: %s", cfgKey.Id)
+		}
+
+		key, err := newSigningKey(cfgKey)
+		if err != nil {
+			return fmt.Errorf("jwt key %s: %w", cfgKey.Id, err)
+		}
+		keys[cfgKey.Id] = key
+	}
+
+	active, ok := keys[activeId]
+	if !ok {
+		return fmt.Errorf("active jwt key %q not found", activeId)
+	}
+	if !active.retireAt.IsZero() {
+		return fmt.Errorf("active jwt key %q must not have retire_at", activeId)
+	}
+
+	k.lock.Lock()
+	defer k.lock.Unlock()
+	k.active = active
+	k.keys = keys
+	return nil
+}
+
+func (k *keyring) signing() *signingKey {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+	return k.active
+}
+
+// verification ищет ключ по kid. Токены без kid выпущены до ротации и проверяются активным ключом
+func (k *keyring) verification(kid string, now time.Time) (*signingKey, error) {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	if kid == "" {
+		return k.active, nil
+	}
+
+	key, ok := k.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown key id: %s", kid)
+	}
+	if !key.retireAt.IsZero() && !now.Before(key.retireAt) {
+		return nil, fmt.Errorf("key %s is retired", kid)
+	}
+
+	return key, nil
+}
+
+func (k *keyring) jwks(now time.Time) domain.JWKS {
+	k.lock.RLock()
+	defer k.lock.RUnlock()
+
+	res := domain.JWKS{Keys: []domain.JWK{}}
+	for _, key := range k.keys {
+		if !key.retireAt.IsZero() && !now.Before(key.retireAt) {
+			continue
+		}
+		if jwk, ok := key.jwk(); ok {
+			res.Keys = append(res.Keys, jwk)
+		}
+	}
+
+	return res
+}
+
+func newSigningKey(cfg config.JwtKey) (*signingKey, error) {
+	key, err := parseSigningKey(cfg)
+	if err != nil {
+		return nil, err
+	}
+	key.id = cfg.Id
+	key.retireAt = cfg.RetireAt
+
+	return key, nil
+}
+
+func parseSigningKey(cfg config.JwtKey) (*signingKey, error) {
+	name := cfg.SigningMethod
+	if name == "" {
+		name = defaultSigningMethod
+	}
+
+	method := jwt.GetSigningMethod(name)
+	if method == nil {
+		return nil, fmt.Errorf("unknown signing method: %s", name)
+	}
+
+	switch method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if cfg.Key == "" {
+			return nil, fmt.Errorf("empty hmac key for %s", name)
+		}
+		return &signingKey{method: method, signKey: []byte(cfg.Key), verifyKey: []byte(cfg.Key)}, nil
+
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS:
+		privateKey, err := jwt.ParseRSAPrivateKeyFromPEM([]byte(cfg.Key))
+		if err != nil {
+			return nil, fmt.Errorf("parse rsa private key: %w", err)
+		}
+		return &signingKey{method: method, signKey: privateKey, verifyKey: &privateKey.PublicKey}, nil
+
+	case *jwt.SigningMethodECDSA:
+		privateKey, err := jwt.ParseECPrivateKeyFromPEM([]byte(cfg.Key))
+		if err != nil {
+			return nil, fmt.Errorf("parse ecdsa private key: %w", err)
+		}
+		return &signingKey{method: method, signKey: privateKey, verifyKey: &privateKey.PublicKey}, nil
+
+	case *signingMethodEdDSA:
+		block, _ := pem.Decode([]byte(cfg.Key))
+		if block == nil {
+			return nil, fmt.Errorf("parse ed25519 private key: key must be PEM encoded")
+		}
+		parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse ed25519 private key: %w", err)
+		}
+		privateKey, ok := parsed.(ed25519.PrivateKey)
+		if !ok {
+			return nil, fmt.Errorf("parse ed25519 private key: not an ed25519 key")
+		}
+		return &signingKey{method: method, signKey: privateKey, verifyKey: privateKey.Public()}, nil
+	}
+
+	return nil, fmt.Errorf("unsupported signing method: %s", name)
+}
+
+// jwk возвращает публичную часть ключа. Для HMAC публиковать нечего
+func (k *signingKey) jwk() (domain.JWK, bool) {
+	res := domain.JWK{
+		Use: "sig",
+		Alg: k.method.Alg(),
+		Kid: k.id,
+	}
+
+	switch key := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		res.Kty = "RSA"
+		res.N = encodeJwkBytes(key.N.Bytes())
+		res.E = encodeJwkBytes(big.NewInt(int64(key.E)).Bytes())
+	case *ecdsa.PublicKey:
+		size := (key.Curve.Params().BitSize + 7) / 8
+		res.Kty = "EC"
+		res.Crv = key.Curve.Params().Name
+		res.X = encodeJwkBytes(key.X.FillBytes(make([]byte, size)))
+		res.Y = encodeJwkBytes(key.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		res.Kty = "OKP"
+		res.Crv = "Ed25519"
+		res.X = encodeJwkBytes(key)
+	default:
+		return domain.JWK{}, false
+	}
+
+	return res, true
+}
+
+func encodeJwkBytes(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
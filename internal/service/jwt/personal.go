@@ -0,0 +1,88 @@
+package jwt
+
+import (
+	"context"
+	"strings"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/utils/encode"
+)
+
+// personalTouchInterval last_used_at обновляется не чаще раза в минуту, чтобы каждый запрос не писал в базу
+const personalTouchInterval = int64(60 * 1000)
+
+// authPersonalToken проверяет персональный токен по хешу. Он заменяет только access токен и не привязан к аудитории
+func (s *service) authPersonalToken(
+	ctx context.Context, token string, purpose domain.AuthPurpose,
+) (domain.Account, domain.TokenClaims, *errors.Error) {
+	if purpose != domain.PurposeAccess {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceError(errors.AuthInvalidTokenPurpose)
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	pat, err := s.personalRepo.GetByHashTX(ctx, tx, encode.HashToken(token))
+	if err != nil {
+		if err == errors.PersonalTokenDoesNotExist {
+			return domain.Account{}, domain.TokenClaims{}, s.log.ServiceError(errors.WD(errors.AuthInvalidToken, err))
+		}
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceDatabaseError(err)
+	}
+
+	now := s.timeAdapter.Now().UnixNano() / 1e+6
+	if pat.ExpiresAt > 0 && pat.ExpiresAt <= now {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceError(errors.AuthExpiredToken)
+	}
+
+	if now-pat.LastUsedAt >= personalTouchInterval {
+		if err = s.personalRepo.TouchTX(ctx, tx, pat.Id, now); err != nil {
+			return domain.Account{}, domain.TokenClaims{}, s.log.ServiceDatabaseError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceTxError(err)
+	}
+
+	claims := domain.TokenClaims{
+		Id:              pat.Id,
+		Issuer:          s.issuer,
+		UserId:          pat.UserId,
+		Role:            domain.Role(pat.Role),
+		Purpose:         domain.PurposeAccess,
+		IssuedAt:        pat.CreatedAt / 1e+3,
+		NotBefore:       pat.CreatedAt / 1e+3,
+		ExpiresAt:       pat.ExpiresAt / 1e+3,
+		Scopes:          strings.Fields(pat.Scope),
+		PersonalTokenId: pat.Id,
+	}
+
+	return domain.Account{
+		Role: claims.Role,
+		Id:   claims.UserId,
+	}, claims, nil
+}
+
+// revokePersonalToken отзыв по RFC 7009: неизвестный токен не ошибка
+func (s *service) revokePersonalToken(ctx context.Context, token string) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if err = s.personalRepo.DeleteByHashTX(ctx, tx, encode.HashToken(token)); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
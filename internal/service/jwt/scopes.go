@@ -0,0 +1,65 @@
+package jwt
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// validateScopes при логине можно только сузить права роли, но не расширить
+func (s *service) validateScopes(role domain.Role, requested []string) *errors.Error {
+	allowed := s.scopes[role]
+	for _, scope := range requested {
+		if !domain.HasScope(allowed, scope) {
+			return errors.WD(errors.AuthInvalidScope, fmt.Errorf("scope %q is not allowed for role %d", scope, role))
+		}
+	}
+
+	return nil
+}
+
+// sessionScopes права, которые получит токен сессии. Скоупы, убранные из конфига после логина, молча отбрасываются
+func (s *service) sessionScopes(role domain.Role, requested []string) []string {
+	allowed := s.scopes[role]
+	if len(requested) == 0 {
+		return allowed
+	}
+
+	res := make([]string, 0, len(requested))
+	for _, scope := range requested {
+		if domain.HasScope(allowed, scope) && !domain.HasScope(res, scope) {
+			res = append(res, scope)
+		}
+	}
+
+	return res
+}
+
+// impersonationTokenScopes права токена имперсонации: запрошенные админом или jwt.impersonation_scopes.
+// В отличие от сессии пользователя пустой результат означает токен без скоупов, а не все права роли
+func (s *service) impersonationTokenScopes(role domain.Role, requested []string) ([]string, *errors.Error) {
+	if len(requested) == 0 {
+		if len(s.impersonationScopes) == 0 {
+			return nil, nil
+		}
+		return s.sessionScopes(role, s.impersonationScopes), nil
+	}
+
+	if e := s.validateScopes(role, requested); e != nil {
+		return nil, e
+	}
+	return s.sessionScopes(role, requested), nil
+}
+
+// scopeClaims scope по RFC 8693 - строка скоупов через пробел
+func scopeClaims(scopes []string) jwt.MapClaims {
+	claims := jwt.MapClaims{}
+	if len(scopes) > 0 {
+		claims["scope"] = strings.Join(scopes, " ")
+	}
+	return claims
+}
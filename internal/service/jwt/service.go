@@ -2,7 +2,9 @@ package jwt
 
 import (
 	"context"
-	"sync"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	randomAdpt "github.com/warehouse/auth-service/internal/adapter/random"
@@ -11,33 +13,66 @@ import (
 	"github.com/warehouse/auth-service/internal/domain"
 	"github.com/warehouse/auth-service/internal/pkg/errors"
 	"github.com/warehouse/auth-service/internal/pkg/logger"
+	rep_converters "github.com/warehouse/auth-service/internal/repository/converters"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
 	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	sessionRepo "github.com/warehouse/auth-service/internal/repository/operations/session"
 	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/dgrijalva/jwt-go"
 )
 
+// defaultServiceSessionLimit каждый вызов client_credentials открывает сессию, поэтому у сервисов лимит есть всегда
+var defaultServiceSessionLimit = config.SessionLimit{Max: 10, Policy: config.SessionPolicyEvictLru}
+
 type (
 	Service interface {
-		Auth(ctx context.Context, token string, purpose domain.AuthPurpose) (domain.Account, int64, *errors.Error)
-		Logout(ctx context.Context, role domain.Role, userId string) *errors.Error
-		CreateTokens(ctx context.Context, role domain.Role, userId string) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
-		CreateTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
-		ReCreateTokens(ctx context.Context, role domain.Role, userId string, number int64) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
+		Auth(ctx context.Context, token string, purpose domain.AuthPurpose, audience string) (domain.Account, domain.TokenClaims, *errors.Error)
+		CreateTokens(ctx context.Context, role domain.Role, userId string, meta domain.SessionMeta) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
+		CreateTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, meta domain.SessionMeta) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
+		ReCreateTokens(ctx context.Context, refreshToken, jkt string) (domain.Account, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error)
 		DropTokens(ctx context.Context, role domain.Role, userId string, number int64) *errors.Error
-		DropOldTokens(ctx context.Context, timestamp int64) *errors.Error
+		Revoke(ctx context.Context, token string) *errors.Error
+		Sessions(ctx context.Context, role domain.Role, userId string) ([]domain.Session, *errors.Error)
+		CreateImpersonationTokenTX(
+			ctx context.Context, tx transactions.Transaction, actorId string, role domain.Role, userId string, meta domain.SessionMeta,
+		) (domain.JwtTokenInfo, int64, *errors.Error)
+		CreateServiceTokenTX(
+			ctx context.Context, tx transactions.Transaction, clientId string, scopes []string, meta domain.SessionMeta,
+		) (domain.JwtTokenInfo, *errors.Error)
+		ExchangeToken(
+			ctx context.Context, subjectToken, actorId, audience string, scopes []string,
+		) (domain.JwtTokenInfo, []string, *errors.Error)
+		VerifyDPoPProof(ctx context.Context, proof string, req domain.DPoPRequest) (string, *errors.Error)
+		CheckDPoPBinding(ctx context.Context, claims domain.TokenClaims, proof string, req domain.DPoPRequest) *errors.Error
+		JWKS() domain.JWKS
+		RotateKeys(cfg config.Auth) error
 	}
 
 	service struct {
 		log logger.Logger
 
-		txRepo transactions.Repository
-		repo   jwtRepo.Repository
+		txRepo       transactions.Repository
+		repo         jwtRepo.Repository
+		sessionRepo  sessionRepo.Repository
+		personalRepo personal_token.Repository
+		dpopRepo     dpop.Repository
 
 		timeAdapter   timeAdpt.Adapter
 		randomAdapter randomAdpt.Adapter
 
-		jwtKey                            string
+		keys                              *keyring
+		issuer                            string
+		audience                          []string
+		sessionLimits                     map[domain.Role]config.SessionLimit
+		sessionIdleTimeout, sessionMaxAge time.Duration
 		atTimeout, rtTimeout, authTimeout time.Duration
-		lock                              *sync.RWMutex
+		impersonationTimeout              time.Duration
+		dpopProofLifetime                 time.Duration
+		scopes                            map[domain.Role][]string
+		impersonationScopes               []string
+		touches                           *sessionTouches
 	}
 )
 
@@ -45,84 +80,120 @@ func NewService(
 	log logger.Logger,
 	txRepo transactions.Repository,
 	repo jwtRepo.Repository,
+	sessionRepo sessionRepo.Repository,
+	personalRepo personal_token.Repository,
+	dpopRepo dpop.Repository,
 	cfg config.Auth,
 	timeAdapter timeAdpt.Adapter,
 	randomAdapter randomAdpt.Adapter,
-) Service {
-	return &service{
-		log:           log,
-		txRepo:        txRepo,
-		repo:          repo,
-		lock:          &sync.RWMutex{},
-		jwtKey:        cfg.Key,
-		atTimeout:     cfg.AccessTokenTimeout,
-		rtTimeout:     cfg.RefreshTokenTimeout,
-		authTimeout:   cfg.AuthTimeout,
-		timeAdapter:   timeAdapter,
-		randomAdapter: randomAdapter,
+) (Service, error) {
+	keys, err := newKeyring(cfg)
+	if err != nil {
+		return nil, err
 	}
-}
 
-func (s *service) Auth(
-	ctx context.Context, token string, purpose domain.AuthPurpose,
-) (domain.Account, int64, *errors.Error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+	if cfg.Issuer == "" || len(cfg.Audience) == 0 {
+		return nil, fmt.Errorf("jwt issuer and audience must be set")
+	}
+	if !slices.Contains(cfg.Audience, cfg.ServiceAudience) {
+		return nil, fmt.Errorf("jwt service audience %q must be one of jwt audience", cfg.ServiceAudience)
+	}
 
-	tx, e := s.txRepo.StartTransaction(ctx)
-	if e != nil {
-		return domain.Account{}, 0, s.log.ServiceTxError(e)
+	impersonationTimeout := cfg.ImpersonationTimeout
+	if impersonationTimeout == 0 {
+		impersonationTimeout = cfg.AccessTokenTimeout
 	}
-	defer tx.Rollback()
 
-	t, err := s.parseToken(token)
-	if err != nil {
-		return domain.Account{}, 0, s.log.ServiceError(err)
+	dpopProofLifetime := cfg.DPoPProofLifetime
+	if dpopProofLifetime == 0 {
+		dpopProofLifetime = defaultDPoPProofLifetime
 	}
 
-	acc, number, err := s.checkToken(ctx, tx, t, purpose)
-	if err != nil {
-		return domain.Account{}, 0, s.log.ServiceError(err)
+	scopes := make(map[domain.Role][]string, len(cfg.Scopes))
+	for role, roleScopes := range cfg.Scopes {
+		scopes[domain.Role(role)] = roleScopes
 	}
 
-	if e = tx.Commit(); e != nil {
-		return domain.Account{}, 0, s.log.ServiceTxError(e)
+	sessionLimits := make(map[domain.Role]config.SessionLimit, len(cfg.SessionLimits))
+	for role, limit := range cfg.SessionLimits {
+		sessionLimits[domain.Role(role)] = limit
+	}
+	if _, ok := sessionLimits[domain.RoleService]; !ok {
+		sessionLimits[domain.RoleService] = defaultServiceSessionLimit
 	}
 
-	return acc, number, nil
+	return &service{
+		log:                  log,
+		txRepo:               txRepo,
+		repo:                 repo,
+		sessionRepo:          sessionRepo,
+		personalRepo:         personalRepo,
+		dpopRepo:             dpopRepo,
+		keys:                 keys,
+		issuer:               cfg.Issuer,
+		audience:             cfg.Audience,
+		sessionLimits:        sessionLimits,
+		scopes:               scopes,
+		impersonationScopes:  cfg.ImpersonationScopes,
+		sessionIdleTimeout:   cfg.SessionIdleTimeout,
+		sessionMaxAge:        cfg.SessionMaxAge,
+		atTimeout:            cfg.AccessTokenTimeout,
+		rtTimeout:            cfg.RefreshTokenTimeout,
+		authTimeout:          cfg.AuthTimeout,
+		impersonationTimeout: impersonationTimeout,
+		dpopProofLifetime:    dpopProofLifetime,
+		timeAdapter:          timeAdapter,
+		randomAdapter:        randomAdapter,
+		touches:              newSessionTouches(),
+	}, nil
 }
 
-func (s *service) Logout(ctx context.Context, role domain.Role, userId string) *errors.Error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// Auth принимает jwt и персональные токены. Персональный токен отличается по префиксу
+func (s *service) Auth(
+	ctx context.Context, token string, purpose domain.AuthPurpose, audience string,
+) (domain.Account, domain.TokenClaims, *errors.Error) {
+	if strings.HasPrefix(token, domain.PersonalTokenPrefix) {
+		return s.authPersonalToken(ctx, token, purpose)
+	}
+
+	t, err := s.parseToken(token)
+	if err != nil {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceError(err)
+	}
 
-	tx, e := s.txRepo.StartTransaction(ctx)
+	// проверка только читает, поэтому идет параллельно без блокировок
+	tx, e := s.txRepo.StartReadOnlyTransaction(ctx)
 	if e != nil {
-		return s.log.ServiceTxError(e)
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceTxError(e)
 	}
 	defer tx.Rollback()
 
-	if err := s.repo.DropAllTokensTX(ctx, tx, role, userId); err != nil {
-		return s.log.ServiceDatabaseError(err)
+	acc, claims, err := s.checkToken(ctx, tx, t, purpose, audience)
+	if err != nil {
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceError(err)
 	}
 
 	if e = tx.Commit(); e != nil {
-		return s.log.ServiceTxError(e)
+		return domain.Account{}, domain.TokenClaims{}, s.log.ServiceTxError(e)
 	}
 
-	return nil
+	if purpose == domain.PurposeAccess {
+		s.touchSession(ctx, claims)
+	}
+
+	return acc, claims, nil
 }
 
-func (s *service) CreateTokens(ctx context.Context, role domain.Role, userId string) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+func (s *service) CreateTokens(
+	ctx context.Context, role domain.Role, userId string, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
 		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
 	}
 	defer tx.Rollback()
 
-	_, accessToken, refreshToken, e := s.createTokens(ctx, tx, role, userId)
+	_, accessToken, refreshToken, e := s.createTokens(ctx, tx, role, userId, meta)
 	if e != nil {
 		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
 	}
@@ -134,10 +205,10 @@ func (s *service) CreateTokens(ctx context.Context, role domain.Role, userId str
 	return accessToken, refreshToken, nil
 }
 
-func (s *service) CreateTokensTX(ctx context.Context, tx transactions.Transaction, role domain.Role, userId string) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
-	_, accessToken, refreshToken, e := s.createTokens(ctx, tx, role, userId)
+func (s *service) CreateTokensTX(
+	ctx context.Context, tx transactions.Transaction, role domain.Role, userId string, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
+	_, accessToken, refreshToken, e := s.createTokens(ctx, tx, role, userId, meta)
 	if e != nil {
 		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
 	}
@@ -145,34 +216,93 @@ func (s *service) CreateTokensTX(ctx context.Context, tx transactions.Transactio
 	return accessToken, refreshToken, nil
 }
 
-func (s *service) ReCreateTokens(ctx context.Context, role domain.Role, userId string, number int64) (domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// ReCreateTokens меняет refresh токен на новую пару в рамках той же сессии и семейства.
+// Повторное предъявление уже обменянного refresh токена отзывает все семейство.
+// jkt - отпечаток ключа из проверенного DPoP proof, для привязанной сессии он обязан совпасть
+func (s *service) ReCreateTokens(
+	ctx context.Context, refreshToken, jkt string,
+) (domain.Account, domain.JwtTokenInfo, domain.JwtTokenInfo, *errors.Error) {
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
-		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
 	}
 	defer tx.Rollback()
 
-	if err := s.repo.DropTokensTX(ctx, tx, role, userId, number); err != nil {
-		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+	t, e := s.parseToken(refreshToken)
+	if e != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	tokenClaims, secret, e := s.verifyClaims(t, domain.PurposeRefresh, "")
+	if e != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	if e = checkDPoPBinding(tokenClaims.Jkt, jkt); e != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	// параллельные обмены одного refresh токена выполняются по очереди, второй увидит уже ротированный токен
+	if err = s.repo.LockUserTX(ctx, tx, tokenClaims.Role, tokenClaims.UserId); err != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceDatabaseError(err)
+	}
+
+	if e = s.checkTokenSecret(ctx, tx, tokenClaims, secret); e != nil {
+		if e.Details != errors.TokenDoesNotExist {
+			return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+		}
+
+		e = s.revokeReusedFamily(ctx, tx, tokenClaims, secret)
+		if e == errors.AuthRefreshTokenReused {
+			if err = tx.Commit(); err != nil {
+				return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+			}
+		}
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	session, e := s.checkSessionAlive(ctx, tx, tokenClaims)
+	if e != nil {
+		if e == errors.AuthSessionExpired {
+			if err = s.repo.DropTokensTX(ctx, tx, tokenClaims.Role, tokenClaims.UserId, tokenClaims.Number); err != nil {
+				return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceDatabaseError(err)
+			}
+			if err = tx.Commit(); err != nil {
+				return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+			}
+		}
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
 	}
 
-	_, accessToken, refreshToken, e := s.createTokens(ctx, tx, role, userId)
+	family, e := s.rotateRefreshToken(ctx, tx, tokenClaims.Role, tokenClaims.UserId, tokenClaims.Number)
 	if e != nil {
-		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(errors.WD(errors.AuthCreateTokens, err))
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	if err = s.sessionRepo.TouchTX(
+		ctx, tx, tokenClaims.Role, tokenClaims.UserId, tokenClaims.Number, s.timeAdapter.Now().UnixNano()/1e+6,
+	); err != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceDatabaseError(err)
+	}
+
+	newAccessToken, newRefreshToken, e := s.issueTokens(ctx, tx, rep_converters.ModelSession2DomainSession(session), family)
+	if e != nil {
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceError(e)
 	}
 
 	if err = tx.Commit(); err != nil {
-		return domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
+		return domain.Account{}, domain.JwtTokenInfo{}, domain.JwtTokenInfo{}, s.log.ServiceTxError(err)
 	}
 
-	return accessToken, refreshToken, nil
+	acc := domain.Account{
+		Role: tokenClaims.Role,
+		Id:   tokenClaims.UserId,
+	}
+	return acc, newAccessToken, newRefreshToken, nil
 }
 
+// DropTokens закрывает одну сессию пользователя, остальные устройства остаются залогинены
 func (s *service) DropTokens(ctx context.Context, role domain.Role, userId string, number int64) *errors.Error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
 		return s.log.ServiceTxError(err)
@@ -191,17 +321,42 @@ func (s *service) DropTokens(ctx context.Context, role domain.Role, userId strin
 	return nil
 }
 
-func (s *service) DropOldTokens(ctx context.Context, timestamp int64) *errors.Error {
-	s.lock.Lock()
-	defer s.lock.Unlock()
+// Revoke отзывает сессию по access или refresh токену (RFC 7009). Просроченный токен тоже принимается,
+// невалидный или уже отозванный токен ошибкой не считается
+func (s *service) Revoke(ctx context.Context, token string) *errors.Error {
+	if strings.HasPrefix(token, domain.PersonalTokenPrefix) {
+		return s.revokePersonalToken(ctx, token)
+	}
+
+	t, e := s.parseExpiredToken(token)
+	if e != nil {
+		return nil
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil
+	}
+
+	tokenClaims, secret, e := s.parseClaims(claims)
+	if e != nil {
+		return nil
+	}
+
 	tx, err := s.txRepo.StartTransaction(ctx)
 	if err != nil {
 		return s.log.ServiceTxError(err)
 	}
 	defer tx.Rollback()
 
-	err = s.repo.DropOldTokens(ctx, tx, timestamp)
-	if err != nil {
+	if e = s.checkTokenSecret(ctx, tx, tokenClaims, secret); e != nil {
+		if e.Details == errors.TokenDoesNotExist {
+			return nil
+		}
+		return s.log.ServiceError(e)
+	}
+
+	if err = s.repo.DropTokensTX(ctx, tx, tokenClaims.Role, tokenClaims.UserId, tokenClaims.Number); err != nil {
 		return s.log.ServiceDatabaseError(err)
 	}
 
@@ -211,3 +366,74 @@ func (s *service) DropOldTokens(ctx context.Context, timestamp int64) *errors.Er
 
 	return nil
 }
+
+// CreateImpersonationTokenTX выпускает access токен пользователя с claim act = actorId.
+// Refresh токен не выдается, поэтому продлить такую сессию нельзя. meta.Scopes - скоупы, запрошенные админом
+func (s *service) CreateImpersonationTokenTX(
+	ctx context.Context, tx transactions.Transaction, actorId string, role domain.Role, userId string, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, int64, *errors.Error) {
+	scopes, e := s.impersonationTokenScopes(role, meta.Scopes)
+	if e != nil {
+		return domain.JwtTokenInfo{}, 0, s.log.ServiceError(e)
+	}
+	meta.Scopes = scopes
+
+	claims := scopeClaims(scopes)
+	claims["act"] = map[string]interface{}{"sub": actorId}
+	meta.ActorId = actorId
+
+	token, number, e := s.issueAccessOnlyTX(ctx, tx, role, userId, meta, s.impersonationTimeout, claims)
+	if e != nil {
+		return domain.JwtTokenInfo{}, 0, s.log.ServiceError(e)
+	}
+
+	return token, number, nil
+}
+
+// CreateServiceTokenTX access токен сервисного аккаунта (client_credentials). Скоупы проверяет вызывающий
+func (s *service) CreateServiceTokenTX(
+	ctx context.Context, tx transactions.Transaction, clientId string, scopes []string, meta domain.SessionMeta,
+) (domain.JwtTokenInfo, *errors.Error) {
+	meta.Scopes = scopes
+	token, _, e := s.issueAccessOnlyTX(ctx, tx, domain.RoleService, clientId, meta, s.atTimeout, scopeClaims(scopes))
+	if e != nil {
+		return domain.JwtTokenInfo{}, s.log.ServiceError(e)
+	}
+
+	return token, nil
+}
+
+// Sessions активные сессии пользователя. last_used обновляется при обмене refresh токена
+// и при использовании access токена, но не чаще раза в минуту
+func (s *service) Sessions(ctx context.Context, role domain.Role, userId string) ([]domain.Session, *errors.Error) {
+	tx, err := s.txRepo.StartReadOnlyTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	sessions, err := s.sessionRepo.GetActiveTX(ctx, tx, role, userId, s.timeAdapter.Now().UnixNano()/1e+6)
+	if err != nil {
+		return nil, s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+
+	res := make([]domain.Session, 0, len(sessions))
+	for _, session := range sessions {
+		res = append(res, rep_converters.ModelSession2DomainSession(session))
+	}
+
+	return res, nil
+}
+
+func (s *service) JWKS() domain.JWKS {
+	return s.keys.jwks(s.timeAdapter.Now())
+}
+
+// RotateKeys подменяет связку ключей без рестарта. Уже выданные токены проверяются по kid
+func (s *service) RotateKeys(cfg config.Auth) error {
+	return s.keys.replace(cfg.Keys, cfg.ActiveKey)
+}
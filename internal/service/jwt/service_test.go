@@ -0,0 +1,146 @@
+package jwt
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	randomAdpt "github.com/warehouse/auth-service/internal/adapter/random"
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/dpop"
+	jwtRepo "github.com/warehouse/auth-service/internal/repository/operations/jwt"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	sessionRepo "github.com/warehouse/auth-service/internal/repository/operations/session"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/dgrijalva/jwt-go"
+	"go.uber.org/zap"
+)
+
+const testAudience = "warehouse"
+
+// newMemoryService сервис на хранилище в памяти, как при storage=memory
+func newMemoryService(tb testing.TB) Service {
+	tb.Helper()
+
+	log := logger.NewLogger(zap.NewNop())
+	client := db.NewMemoryClient()
+	s, err := NewService(
+		log,
+		transactions.NewMemoryRepository(client),
+		jwtRepo.NewMemoryRepository(log, client),
+		sessionRepo.NewMemoryRepository(log, client),
+		personal_token.NewMemoryRepository(log, client),
+		dpop.NewMemoryRepository(log, client),
+		config.Auth{
+			Keys:                []config.JwtKey{{Id: "test", SigningMethod: "HS256", Key: "testSecret"}},
+			ActiveKey:           "test",
+			Issuer:              "warehouse-auth",
+			Audience:            []string{testAudience},
+			ServiceAudience:     testAudience,
+			AccessTokenTimeout:  time.Minute,
+			RefreshTokenTimeout: time.Hour,
+		},
+		timeAdpt.NewAdapter(3),
+		randomAdpt.NewAdapter(),
+	)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return s
+}
+
+func TestReCreateTokensReuseRevokesFamily(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryService(t)
+	_, refresh, e := s.CreateTokens(ctx, domain.RoleUser, "user", domain.SessionMeta{})
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	_, access, rotated, e := s.ReCreateTokens(ctx, refresh.Token, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+
+	if _, _, _, e = s.ReCreateTokens(ctx, refresh.Token, ""); e != errors.AuthRefreshTokenReused {
+		t.Fatalf("reuse err = %v, want %v", e, errors.AuthRefreshTokenReused)
+	}
+
+	// после повторного предъявления семейство отозвано целиком, в том числе выданная ротацией пара
+	if _, _, _, e = s.ReCreateTokens(ctx, rotated.Token, ""); e == nil {
+		t.Fatal("refresh token of the revoked family must be rejected")
+	}
+	if _, _, e = s.Auth(ctx, access.Token, domain.PurposeAccess, testAudience); e == nil {
+		t.Fatal("access token of the revoked family must be rejected")
+	}
+}
+
+func TestAuthChecksSignatureBeforeExpiry(t *testing.T) {
+	ctx := context.Background()
+	s := newMemoryService(t)
+	claims := jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}
+
+	for _, tt := range []struct {
+		name, secret string
+		want         *errors.Error
+	}{
+		{"forged", "otherSecret", errors.AuthInvalidToken},
+		{"signed", "testSecret", errors.AuthExpiredToken},
+	} {
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+		token.Header["kid"] = "test"
+		signed, err := token.SignedString([]byte(tt.secret))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, _, e := s.Auth(ctx, signed, domain.PurposeAccess, testAudience); e != tt.want {
+			t.Errorf("%s expired token err = %v, want %v", tt.name, e, tt.want)
+		}
+	}
+}
+
+func BenchmarkAuth(b *testing.B) {
+	ctx := context.Background()
+	s := newMemoryService(b)
+	access, _, err := s.CreateTokens(ctx, domain.RoleUser, "user", domain.SessionMeta{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, _, err := s.Auth(ctx, access.Token, domain.PurposeAccess, testAudience); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkAuthParallel проверки токенов не должны выстраиваться в очередь друг за другом
+func BenchmarkAuthParallel(b *testing.B) {
+	ctx := context.Background()
+	s := newMemoryService(b)
+	access, _, err := s.CreateTokens(ctx, domain.RoleUser, "user", domain.SessionMeta{})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			if _, _, err := s.Auth(ctx, access.Token, domain.PurposeAccess, testAudience); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
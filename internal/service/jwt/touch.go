@@ -0,0 +1,70 @@
+package jwt
+
+import (
+	"context"
+	"sync"
+
+	"github.com/warehouse/auth-service/internal/domain"
+)
+
+// sessionTouchesLimit при переполнении учет сбрасывается целиком: худшее, что будет, лишняя запись в базу
+const sessionTouchesLimit = 100000
+
+type (
+	sessionKey struct {
+		role   domain.Role
+		userId string
+		number int64
+	}
+
+	// sessionTouches когда сессия в последний раз записывалась в базу этим инстансом.
+	// Проверка access токена только читает, и без учета каждый запрос писал бы last_used_at
+	sessionTouches struct {
+		mu   sync.Mutex
+		last map[sessionKey]int64
+	}
+)
+
+func newSessionTouches() *sessionTouches {
+	return &sessionTouches{last: make(map[sessionKey]int64)}
+}
+
+// due отмечает касание и возвращает true, если с прошлой записи прошло больше personalTouchInterval
+func (t *sessionTouches) due(key sessionKey, now int64) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if now-t.last[key] < personalTouchInterval {
+		return false
+	}
+	if len(t.last) >= sessionTouchesLimit {
+		t.last = make(map[sessionKey]int64)
+	}
+	t.last[key] = now
+	return true
+}
+
+// touchSession обновляет last_used_at сессии при использовании access токена.
+// Ошибка только логируется: запрос с валидным токеном из-за нее не падает
+func (s *service) touchSession(ctx context.Context, claims domain.TokenClaims) {
+	now := s.timeAdapter.Now().UnixNano() / 1e+6
+	if !s.touches.due(sessionKey{claims.Role, claims.UserId, claims.Number}, now) {
+		return
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		s.log.ServiceTxError(err)
+		return
+	}
+	defer tx.Rollback()
+
+	if err = s.sessionRepo.TouchTX(ctx, tx, claims.Role, claims.UserId, claims.Number, now); err != nil {
+		s.log.ServiceDatabaseError(err)
+		return
+	}
+
+	if err = tx.Commit(); err != nil {
+		s.log.ServiceTxError(err)
+	}
+}
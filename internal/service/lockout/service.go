@@ -0,0 +1,266 @@
+package lockout
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	mailAdpt "github.com/warehouse/auth-service/internal/adapter/mail"
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	userAdpt "github.com/warehouse/auth-service/internal/adapter/user"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultBaseDelay = time.Minute
+	defaultMaxDelay  = time.Hour
+	defaultWindow    = time.Hour
+)
+
+type (
+	Service interface {
+		// Attempt вызывается до проверки пароля и заранее учитывает попытку как неудачную в той же транзакции,
+		// что и проверка блокировки, поэтому из параллельных попыток до пароля дойдет не больше порога.
+		// Если заблокирован логин или ip, возвращает AuthLoginLocked с retry_after, и попытка не учитывается
+		Attempt(ctx context.Context, login, ip string) (Attempt, *errors.Error)
+		// Fail неверный пароль, попытка уже учтена. О первой блокировке логина владельцу уходит письмо
+		Fail(ctx context.Context, attempt Attempt)
+		// Succeed верный пароль: счетчик логина сбрасывается, а со счетчика ip попытка снимается
+		Succeed(ctx context.Context, attempt Attempt) *errors.Error
+		// Release пароль не проверялся, например не ответил пользовательский сервис. Попытка снимается с обоих счетчиков
+		Release(ctx context.Context, attempt Attempt) *errors.Error
+		// Unlock снимает блокировку логина админом
+		Unlock(ctx context.Context, admin domain.Account, login string) *errors.Error
+	}
+
+	// Attempt попытка входа, учтенная Attempt. Нулевое значение - попытка без счетчиков
+	Attempt struct {
+		login    string
+		counters []counter
+		// accountLockedUntil блокировка логина, которую поставила эта попытка. Письмо уходит, только если пароль неверный
+		accountLockedUntil time.Time
+	}
+
+	counter struct {
+		scope     string
+		key       string
+		threshold int64
+	}
+
+	service struct {
+		log logger.Logger
+		cfg config.Lockout
+
+		txRepo      transactions.Repository
+		failureRepo login_failure.Repository
+
+		timeAdapter timeAdpt.Adapter
+		userAdapter userAdpt.Adapter
+		mailAdapter mailAdpt.Adapter
+	}
+)
+
+func NewService(
+	log logger.Logger,
+	cfg config.Lockout,
+	txRepo transactions.Repository,
+	failureRepo login_failure.Repository,
+	timeAdapter timeAdpt.Adapter,
+	userAdapter userAdpt.Adapter,
+	mailAdapter mailAdpt.Adapter,
+) Service {
+	if cfg.BaseDelay <= 0 {
+		cfg.BaseDelay = defaultBaseDelay
+	}
+	if cfg.MaxDelay <= 0 {
+		cfg.MaxDelay = defaultMaxDelay
+	}
+	if cfg.Window <= 0 {
+		cfg.Window = defaultWindow
+	}
+
+	return &service{
+		log:         log.Named("lockout"),
+		cfg:         cfg,
+		txRepo:      txRepo,
+		failureRepo: failureRepo,
+		timeAdapter: timeAdapter,
+		userAdapter: userAdapter,
+		mailAdapter: mailAdapter,
+	}
+}
+
+// Attempt попытка, которая довела счетчик до порога, сразу ставит блокировку: следующие параллельные попытки
+// увидят ее, не дожидаясь проверки пароля этой
+func (s *service) Attempt(ctx context.Context, login, ip string) (Attempt, *errors.Error) {
+	attempt := Attempt{login: login, counters: s.counters(login, ip)}
+	if len(attempt.counters) == 0 {
+		return attempt, nil
+	}
+
+	now := s.timeAdapter.Now()
+	nowMilli := now.UnixNano() / 1e+6
+	expiresAt := now.Add(s.cfg.Window).UnixNano() / 1e+6
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return Attempt{}, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	var lockedUntil int64
+	for _, c := range attempt.counters {
+		failure, err := s.failureRepo.AddFailureTX(ctx, tx, c.scope, c.key, nowMilli, expiresAt)
+		if err != nil {
+			return Attempt{}, s.log.ServiceDatabaseError(err)
+		}
+		if failure.LockedUntil > nowMilli {
+			lockedUntil = max(lockedUntil, failure.LockedUntil)
+			continue
+		}
+		if failure.Failures < c.threshold {
+			continue
+		}
+
+		until := now.Add(s.lockDelay(failure.Failures - c.threshold))
+		if err = s.failureRepo.LockTX(ctx, tx, c.scope, c.key, until.UnixNano()/1e+6); err != nil {
+			return Attempt{}, s.log.ServiceDatabaseError(err)
+		}
+
+		s.log.Info("login locked", zap.String("scope", c.scope), zap.String("key", c.key), zap.Time("until", until))
+		if c.scope == domain.LockoutScopeAccount && failure.Failures == c.threshold {
+			attempt.accountLockedUntil = until
+		}
+	}
+
+	// отказ откатывает транзакцию: попытки во время блокировки ее не продлевают
+	if lockedUntil > 0 {
+		retryAfter := (lockedUntil - nowMilli + 999) / 1e+3
+		return Attempt{}, errors.WP(errors.AuthLoginLocked, models.LockoutPayload{RetryAfter: retryAfter})
+	}
+
+	if err = tx.Commit(); err != nil {
+		return Attempt{}, s.log.ServiceTxError(err)
+	}
+
+	return attempt, nil
+}
+
+func (s *service) Fail(ctx context.Context, attempt Attempt) {
+	if !attempt.accountLockedUntil.IsZero() {
+		s.notifyLocked(ctx, attempt.login, attempt.accountLockedUntil)
+	}
+}
+
+func (s *service) Succeed(ctx context.Context, attempt Attempt) *errors.Error {
+	return s.settle(ctx, attempt, true)
+}
+
+func (s *service) Release(ctx context.Context, attempt Attempt) *errors.Error {
+	return s.settle(ctx, attempt, false)
+}
+
+// settle снимает заранее учтенную попытку. После верного пароля счетчик логина удаляется целиком,
+// а счетчик ip только уменьшается: с одного адреса могут подбирать пароли к другим логинам
+func (s *service) settle(ctx context.Context, attempt Attempt, succeeded bool) *errors.Error {
+	if len(attempt.counters) == 0 {
+		return nil
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	for _, c := range attempt.counters {
+		if succeeded && c.scope == domain.LockoutScopeAccount {
+			err = s.failureRepo.DeleteTX(ctx, tx, c.scope, c.key)
+		} else {
+			err = s.failureRepo.ForgiveTX(ctx, tx, c.scope, c.key, c.threshold)
+		}
+		if err != nil {
+			return s.log.ServiceDatabaseError(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
+
+func (s *service) Unlock(ctx context.Context, admin domain.Account, login string) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if err = s.failureRepo.DeleteTX(ctx, tx, domain.LockoutScopeAccount, normalizeLogin(login)); err != nil {
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	s.log.Info("login unlocked", zap.String("login", login), zap.String("admin_id", admin.Id))
+	return nil
+}
+
+// counters счетчики, которые включены в конфиге. Логин приводится к нижнему регистру,
+// чтобы блокировку нельзя было обойти, меняя регистр
+func (s *service) counters(login, ip string) []counter {
+	var counters []counter
+	if s.cfg.Threshold > 0 && login != "" {
+		counters = append(counters, counter{domain.LockoutScopeAccount, normalizeLogin(login), int64(s.cfg.Threshold)})
+	}
+	if s.cfg.IpThreshold > 0 && ip != "" {
+		counters = append(counters, counter{domain.LockoutScopeIp, ip, int64(s.cfg.IpThreshold)})
+	}
+	return counters
+}
+
+// lockDelay первая блокировка BaseDelay, каждая следующая неудачная попытка удваивает ее до MaxDelay
+func (s *service) lockDelay(overThreshold int64) time.Duration {
+	delay := s.cfg.BaseDelay
+	for i := int64(0); i < overThreshold && delay < s.cfg.MaxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, s.cfg.MaxDelay)
+}
+
+// notifyLocked письмо владельцу логина. Логин может не существовать, тогда писать некому
+func (s *service) notifyLocked(ctx context.Context, login string, lockedUntil time.Time) {
+	acc, err := s.userAdapter.GetByLogin(ctx, login)
+	if err != nil {
+		return
+	}
+
+	mail := domain.EmailMessage{
+		To:   acc.Email,
+		Type: domain.AccountLockedType,
+		Payload: domain.Payload{
+			Firstname:   acc.Firstname,
+			LockPayload: domain.LockPayload{LockedUntil: lockedUntil.Unix()},
+		},
+	}
+
+	if err = s.mailAdapter.SendMessage(mail); err != nil {
+		s.log.ServiceBrokerAdapterError(err)
+	}
+}
+
+func normalizeLogin(login string) string {
+	return strings.ToLower(strings.TrimSpace(login))
+}
@@ -0,0 +1,235 @@
+package lockout
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	userAdpt "github.com/warehouse/auth-service/internal/adapter/user"
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/db"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/repository/operations/login_failure"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"go.uber.org/zap"
+)
+
+const testLogin = "user"
+
+type (
+	// clock время, которое тест двигает сам
+	clock struct {
+		timeAdpt.Adapter
+		mu  sync.Mutex
+		now time.Time
+	}
+
+	users struct {
+		userAdpt.Adapter
+	}
+
+	mailbox struct {
+		mu       sync.Mutex
+		messages []domain.EmailMessage
+	}
+)
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func (u users) GetByLogin(ctx context.Context, username string) (domain.Account, error) {
+	return domain.Account{Username: username, Email: username + "@example.com"}, nil
+}
+
+func (m *mailbox) SendMessage(message domain.EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messages = append(m.messages, message)
+	return nil
+}
+
+func newMemoryService(t *testing.T, cfg config.Lockout) (Service, *clock, *mailbox) {
+	t.Helper()
+
+	log := logger.NewLogger(zap.NewNop())
+	client := db.NewMemoryClient()
+	c := &clock{Adapter: timeAdpt.NewAdapter(3), now: time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)}
+	mail := &mailbox{}
+	s := NewService(
+		log,
+		cfg,
+		transactions.NewMemoryRepository(client),
+		login_failure.NewMemoryRepository(log, client),
+		c,
+		users{},
+		mail,
+	)
+
+	return s, c, mail
+}
+
+// retryAfter секунды из отказа AuthLoginLocked
+func retryAfter(t *testing.T, e *errors.Error) int64 {
+	t.Helper()
+
+	if e == nil || e.Code != errors.AuthLoginLocked.Code || e.Reason != errors.AuthLoginLocked.Reason {
+		t.Fatalf("err = %v, want %v", e, errors.AuthLoginLocked)
+	}
+	payload, ok := e.Payload.(models.LockoutPayload)
+	if !ok {
+		t.Fatalf("payload = %#v, want models.LockoutPayload", e.Payload)
+	}
+	return payload.RetryAfter
+}
+
+func TestAttemptBurstAdmitsThreshold(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryService(t, config.Lockout{Threshold: 5})
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		admitted int
+	)
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, e := s.Attempt(ctx, testLogin, ""); e == nil {
+				mu.Lock()
+				admitted++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if admitted != 5 {
+		t.Fatalf("admitted %d parallel attempts, want 5", admitted)
+	}
+}
+
+func TestAttemptLockDoublesUpToMaxDelay(t *testing.T) {
+	ctx := context.Background()
+	s, c, mail := newMemoryService(t, config.Lockout{Threshold: 2, BaseDelay: time.Minute, MaxDelay: 3 * time.Minute})
+
+	if _, e := s.Attempt(ctx, testLogin, ""); e != nil {
+		t.Fatal(e)
+	}
+	attempt, e := s.Attempt(ctx, testLogin, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	s.Fail(ctx, attempt)
+	if len(mail.messages) != 1 || mail.messages[0].Type != domain.AccountLockedType {
+		t.Fatalf("messages = %+v, want one account locked notification", mail.messages)
+	}
+
+	for _, want := range []int64{60, 120, 180, 180} {
+		_, e = s.Attempt(ctx, "USER", "")
+		if got := retryAfter(t, e); got != want {
+			t.Fatalf("retry_after = %d, want %d", got, want)
+		}
+
+		// отказ во время блокировки ее не продлевает
+		c.advance(time.Duration(want)*time.Second + time.Second)
+		attempt, e = s.Attempt(ctx, testLogin, "")
+		if e != nil {
+			t.Fatal(e)
+		}
+		s.Fail(ctx, attempt)
+	}
+
+	if len(mail.messages) != 1 {
+		t.Fatalf("got %d notifications, want only the first lock to be reported", len(mail.messages))
+	}
+}
+
+func TestSucceedResetsAccountCounter(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryService(t, config.Lockout{Threshold: 2, IpThreshold: 3})
+
+	attempt, e := s.Attempt(ctx, testLogin, "10.0.0.1")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = s.Succeed(ctx, attempt); e != nil {
+		t.Fatal(e)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, e = s.Attempt(ctx, testLogin, "10.0.0.1"); e != nil {
+			t.Fatalf("attempt %d after a successful login: %v", i+1, e)
+		}
+	}
+	if _, e = s.Attempt(ctx, testLogin, "10.0.0.1"); e == nil {
+		t.Fatal("attempt over the threshold must be locked")
+	}
+}
+
+func TestIpCounterLocksOtherLogins(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryService(t, config.Lockout{Threshold: 10, IpThreshold: 2})
+
+	for _, login := range []string{"first", "second"} {
+		if _, e := s.Attempt(ctx, login, "10.0.0.1"); e != nil {
+			t.Fatal(e)
+		}
+	}
+	_, e := s.Attempt(ctx, "third", "10.0.0.1")
+	retryAfter(t, e)
+
+	if _, e = s.Attempt(ctx, "third", "10.0.0.2"); e != nil {
+		t.Fatalf("attempt from another ip: %v", e)
+	}
+}
+
+func TestReleaseDoesNotCountAttempt(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryService(t, config.Lockout{Threshold: 1})
+
+	attempt, e := s.Attempt(ctx, testLogin, "")
+	if e != nil {
+		t.Fatal(e)
+	}
+	if e = s.Release(ctx, attempt); e != nil {
+		t.Fatal(e)
+	}
+
+	if _, e = s.Attempt(ctx, testLogin, ""); e != nil {
+		t.Fatalf("released attempt must not lock the login: %v", e)
+	}
+}
+
+func TestUnlock(t *testing.T) {
+	ctx := context.Background()
+	s, _, _ := newMemoryService(t, config.Lockout{Threshold: 1})
+
+	if _, e := s.Attempt(ctx, testLogin, ""); e != nil {
+		t.Fatal(e)
+	}
+	_, e := s.Attempt(ctx, testLogin, "")
+	retryAfter(t, e)
+
+	if e = s.Unlock(ctx, domain.Account{Id: "admin", Role: domain.RoleAdmin}, " User "); e != nil {
+		t.Fatal(e)
+	}
+	if _, e = s.Attempt(ctx, testLogin, ""); e != nil {
+		t.Fatalf("attempt after unlock: %v", e)
+	}
+}
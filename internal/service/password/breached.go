@@ -0,0 +1,56 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// breachedList sha1 утекших паролей. Пустой список ничего не запрещает
+type breachedList map[[sha1.Size]byte]struct{}
+
+// loadBreached читает файл целиком при старте. Пустые строки и строки с # пропускаются,
+// битый хеш - ошибка, чтобы опечатка в файле не отключала проверку молча
+func loadBreached(path string) (breachedList, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open breached passwords: %w", err)
+	}
+	defer file.Close()
+
+	list := make(breachedList)
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		hash, _, _ := strings.Cut(text, ":")
+		sum, err := hex.DecodeString(hash)
+		if err != nil || len(sum) != sha1.Size {
+			return nil, fmt.Errorf("breached passwords %s:%d: invalid sha1 %q", path, line, hash)
+		}
+		list[[sha1.Size]byte(sum)] = struct{}{}
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read breached passwords: %w", err)
+	}
+
+	return list, nil
+}
+
+func (l breachedList) contains(password string) bool {
+	if len(l) == 0 {
+		return false
+	}
+	_, ok := l[sha1.Sum([]byte(password))]
+	return ok
+}
@@ -0,0 +1,188 @@
+package password
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+// bcryptMaxLength bcrypt молча отбрасывает все после 72 байт
+const bcryptMaxLength = 72
+
+// identityMinLength более короткие логин и почта не проверяются, иначе под запрет попадут случайные пароли
+const identityMinLength = 3
+
+const (
+	CodeTooShort         = "too_short"
+	CodeTooLong          = "too_long"
+	CodeMissingClasses   = "missing_classes"
+	CodeContainsUsername = "contains_username"
+	CodeContainsEmail    = "contains_email"
+	CodeTooWeak          = "too_weak"
+	CodeBreached         = "breached"
+)
+
+type (
+	Service interface {
+		// Validate проверяет пароль по политике и возвращает ValidationFailed со всеми нарушениями в payload.
+		// field имя поля запроса, owner логин и почта, которые пароль не должен содержать
+		Validate(field, password string, owner domain.Account) *errors.Error
+	}
+
+	service struct {
+		log      logger.Logger
+		cfg      config.PasswordPolicy
+		breached breachedList
+	}
+)
+
+func NewService(log logger.Logger, cfg config.PasswordPolicy) (Service, error) {
+	breached, err := loadBreached(cfg.BreachedFile)
+	if err != nil {
+		return nil, err
+	}
+
+	log = log.Named("password")
+	if cfg.BreachedFile != "" {
+		log.Info("breached passwords loaded", zap.Int("hashes", len(breached)))
+	}
+
+	return &service{
+		log:      log,
+		cfg:      cfg,
+		breached: breached,
+	}, nil
+}
+
+func (s *service) Validate(field, password string, owner domain.Account) *errors.Error {
+	var violations []models.FieldError
+	violate := func(code, message string) {
+		violations = append(violations, models.FieldError{Field: field, Code: code, Message: message})
+	}
+
+	if length := utf8.RuneCountInString(password); length < s.cfg.MinLength {
+		violate(CodeTooShort, fmt.Sprintf("must be at least %d characters", s.cfg.MinLength))
+	}
+	if len(password) > bcryptMaxLength {
+		violate(CodeTooLong, fmt.Sprintf("must be at most %d bytes", bcryptMaxLength))
+	}
+	if classesOf(password).count() < s.cfg.MinClasses {
+		violate(CodeMissingClasses, fmt.Sprintf(
+			"must contain at least %d of: lowercase letters, uppercase letters, digits, symbols", s.cfg.MinClasses,
+		))
+	}
+
+	lower := strings.ToLower(password)
+	if containsIdentity(lower, owner.Username) {
+		violate(CodeContainsUsername, "must not contain the username")
+	}
+	email := strings.ToLower(owner.Email)
+	local, _, _ := strings.Cut(email, "@")
+	if containsIdentity(lower, email) || containsIdentity(lower, local) {
+		violate(CodeContainsEmail, "must not contain the email")
+	}
+
+	if s.cfg.MinEntropy > 0 && entropy(password) < s.cfg.MinEntropy {
+		violate(CodeTooWeak, "is too easy to guess")
+	}
+	if s.breached.contains(password) {
+		violate(CodeBreached, "appeared in a data breach")
+	}
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return errors.WP(errors.ValidationFailed, models.FieldErrorsPayload{Fields: violations})
+}
+
+func containsIdentity(lowerPassword, identity string) bool {
+	identity = strings.ToLower(strings.TrimSpace(identity))
+	return utf8.RuneCountInString(identity) >= identityMinLength && strings.Contains(lowerPassword, identity)
+}
+
+type classes struct {
+	lower, upper, digit, other bool
+}
+
+// classesOf строчные, заглавные, цифры и все остальное
+func classesOf(password string) classes {
+	var c classes
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			c.lower = true
+		case unicode.IsUpper(r):
+			c.upper = true
+		case unicode.IsDigit(r):
+			c.digit = true
+		default:
+			c.other = true
+		}
+	}
+	return c
+}
+
+func (c classes) count() int {
+	var n int
+	for _, ok := range []bool{c.lower, c.upper, c.digit, c.other} {
+		if ok {
+			n++
+		}
+	}
+	return n
+}
+
+// alphabet сколько символов в использованных классах
+func (c classes) alphabet() int {
+	var n int
+	if c.lower {
+		n += 26
+	}
+	if c.upper {
+		n += 26
+	}
+	if c.digit {
+		n += 10
+	}
+	if c.other {
+		n += 33
+	}
+	return n
+}
+
+// entropy грубая оценка стойкости в битах без словарей. Каждый символ дает log2 алфавита из использованных классов,
+// а повтор предыдущего символа или продолжение последовательности (abc, 321) только один бит
+func entropy(password string) float64 {
+	alphabet := classesOf(password).alphabet()
+	if alphabet == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(alphabet))
+	runes := []rune(password)
+
+	var bits float64
+	for i, r := range runes {
+		step := r - runes[max(i-1, 0)]
+		switch {
+		case i > 0 && step == 0:
+			bits++
+		case i > 1 && (step == 1 || step == -1) && step == runes[i-1]-runes[i-2]:
+			bits++
+		default:
+			bits += perChar
+		}
+	}
+	return bits
+}
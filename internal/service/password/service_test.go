@@ -0,0 +1,121 @@
+package password
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/warehouse/auth-service/internal/config"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+const breachedPassword = "Correct-Horse-42"
+
+func newTestService(t *testing.T) Service {
+	t.Helper()
+
+	sum := sha1.Sum([]byte(breachedPassword))
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	content := "# hibp sample\n\n" + strings.ToUpper(hex.EncodeToString(sum[:])) + ":3861493\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := NewService(logger.NewLogger(zap.NewNop()), config.PasswordPolicy{
+		MinLength:    10,
+		MinClasses:   3,
+		MinEntropy:   40,
+		BreachedFile: path,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestValidate(t *testing.T) {
+	s := newTestService(t)
+	owner := domain.Account{Username: "someone", Email: "someone@example.com"}
+
+	tests := []struct {
+		name     string
+		password string
+		owner    domain.Account
+		want     []string
+	}{
+		{name: "valid", password: "Tr0ub4dor&3-Zebra"},
+		{name: "too short", password: "Short1!", want: []string{CodeTooShort}},
+		{name: "too long", password: "Aa1!" + strings.Repeat("xyz", 23), want: []string{CodeTooLong}},
+		{name: "missing classes", password: "onlylowercaseletters", want: []string{CodeMissingClasses}},
+		{
+			name:     "contains username",
+			password: "Ivan.Petrov#2026",
+			owner:    domain.Account{Username: "IVAN.PETROV", Email: "petrov.i@example.com"},
+			want:     []string{CodeContainsUsername},
+		},
+		{
+			name:     "contains email",
+			password: "Mail-Owner-9!x",
+			owner:    domain.Account{Username: "someone", Email: "mail-owner@example.com"},
+			want:     []string{CodeContainsEmail},
+		},
+		{name: "too weak", password: "Aaaaaaaaaa1!", want: []string{CodeTooWeak}},
+		{name: "breached", password: breachedPassword, want: []string{CodeBreached}},
+		{name: "all violations at once", password: "aaaa", want: []string{CodeTooShort, CodeMissingClasses, CodeTooWeak}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.owner == (domain.Account{}) {
+				tt.owner = owner
+			}
+
+			e := s.Validate("new_password", tt.password, tt.owner)
+			if len(tt.want) == 0 {
+				if e != nil {
+					t.Fatalf("err = %v, want nil", e.Payload)
+				}
+				return
+			}
+
+			if e == nil || e.Code != errors.ValidationFailed.Code || e.Reason != errors.ValidationFailed.Reason {
+				t.Fatalf("err = %v, want %v", e, errors.ValidationFailed)
+			}
+			payload, ok := e.Payload.(models.FieldErrorsPayload)
+			if !ok {
+				t.Fatalf("payload = %#v, want models.FieldErrorsPayload", e.Payload)
+			}
+
+			var codes []string
+			for _, field := range payload.Fields {
+				if field.Field != "new_password" {
+					t.Errorf("field = %q, want new_password", field.Field)
+				}
+				codes = append(codes, field.Code)
+			}
+			if !slices.Equal(codes, tt.want) {
+				t.Fatalf("codes = %v, want %v", codes, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewServiceRejectsInvalidBreachedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(path, []byte("not-a-sha1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewService(logger.NewLogger(zap.NewNop()), config.PasswordPolicy{BreachedFile: path}); err == nil {
+		t.Fatal("invalid hash in the breached file must fail the start")
+	}
+}
@@ -0,0 +1,164 @@
+package pat
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	timeAdpt "github.com/warehouse/auth-service/internal/adapter/time"
+	"github.com/warehouse/auth-service/internal/domain"
+	"github.com/warehouse/auth-service/internal/handler/models"
+	"github.com/warehouse/auth-service/internal/pkg/errors"
+	"github.com/warehouse/auth-service/internal/pkg/logger"
+	"github.com/warehouse/auth-service/internal/pkg/utils/encode"
+	rep_converters "github.com/warehouse/auth-service/internal/repository/converters"
+	repModels "github.com/warehouse/auth-service/internal/repository/models"
+	"github.com/warehouse/auth-service/internal/repository/operations/personal_token"
+	"github.com/warehouse/auth-service/internal/repository/operations/transactions"
+
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+)
+
+const tokenLength = 32
+
+type (
+	Service interface {
+		// Create возвращает токен в открытом виде. Он показывается один раз, в базе лежит только хеш
+		Create(
+			ctx context.Context, role domain.Role, userId string, allowedScopes []string, reqData models.CreatePersonalTokenRequest,
+		) (domain.PersonalToken, string, *errors.Error)
+		List(ctx context.Context, role domain.Role, userId string) ([]domain.PersonalToken, *errors.Error)
+		Revoke(ctx context.Context, role domain.Role, userId, id string) *errors.Error
+	}
+
+	service struct {
+		log logger.Logger
+
+		txRepo       transactions.Repository
+		personalRepo personal_token.Repository
+
+		timeAdapter timeAdpt.Adapter
+	}
+)
+
+func NewService(
+	log logger.Logger,
+	txRepo transactions.Repository,
+	personalRepo personal_token.Repository,
+	timeAdapter timeAdpt.Adapter,
+) Service {
+	return &service{
+		log:          log.Named("personal_tokens"),
+		txRepo:       txRepo,
+		personalRepo: personalRepo,
+		timeAdapter:  timeAdapter,
+	}
+}
+
+// Create allowedScopes - скоупы токена, которым создают персональный токен. Шире них выдать нельзя
+func (s *service) Create(
+	ctx context.Context, role domain.Role, userId string, allowedScopes []string, reqData models.CreatePersonalTokenRequest,
+) (domain.PersonalToken, string, *errors.Error) {
+	if reqData.Name == "" {
+		return domain.PersonalToken{}, "", errors.WD(errors.ValidationFailed, errors.New("name is required"))
+	}
+	if reqData.ExpiresIn < 0 {
+		return domain.PersonalToken{}, "", errors.WD(errors.ValidationFailed, errors.New("expires_in must not be negative"))
+	}
+
+	scopes := allowedScopes
+	if requested := strings.Fields(reqData.Scope); len(requested) > 0 {
+		for _, scope := range requested {
+			if !domain.HasScope(allowedScopes, scope) {
+				return domain.PersonalToken{}, "", errors.WD(errors.AuthInvalidScope, fmt.Errorf("scope %q is not granted to caller", scope))
+			}
+		}
+		scopes = requested
+	}
+
+	secret, err := encode.RandomToken(tokenLength)
+	if err != nil {
+		return domain.PersonalToken{}, "", s.log.ServiceError(errors.WD(errors.InternalError, err))
+	}
+	token := domain.PersonalTokenPrefix + secret
+
+	now := s.timeAdapter.Now()
+	var expiresAt int64
+	if reqData.ExpiresIn > 0 {
+		expiresAt = now.Add(time.Duration(reqData.ExpiresIn)*time.Second).UnixNano() / 1e+6
+	}
+
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return domain.PersonalToken{}, "", s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	pat := repModels.PersonalToken{
+		Id:        xid.New().String(),
+		Role:      int64(role),
+		UserId:    userId,
+		Name:      reqData.Name,
+		TokenHash: encode.HashToken(token),
+		Scope:     strings.Join(scopes, " "),
+		CreatedAt: now.UnixNano() / 1e+6,
+		ExpiresAt: expiresAt,
+	}
+	if err = s.personalRepo.CreateTX(ctx, tx, pat); err != nil {
+		return domain.PersonalToken{}, "", s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return domain.PersonalToken{}, "", s.log.ServiceTxError(err)
+	}
+
+	s.log.Info("personal access token created", zap.String("user_id", userId), zap.String("token_id", pat.Id))
+	return rep_converters.ModelPersonalToken2DomainPersonalToken(pat), token, nil
+}
+
+func (s *service) List(ctx context.Context, role domain.Role, userId string) ([]domain.PersonalToken, *errors.Error) {
+	tx, err := s.txRepo.StartReadOnlyTransaction(ctx)
+	if err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	tokens, err := s.personalRepo.ListTX(ctx, tx, role, userId)
+	if err != nil {
+		return nil, s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, s.log.ServiceTxError(err)
+	}
+
+	res := make([]domain.PersonalToken, 0, len(tokens))
+	for _, token := range tokens {
+		res = append(res, rep_converters.ModelPersonalToken2DomainPersonalToken(token))
+	}
+
+	return res, nil
+}
+
+func (s *service) Revoke(ctx context.Context, role domain.Role, userId, id string) *errors.Error {
+	tx, err := s.txRepo.StartTransaction(ctx)
+	if err != nil {
+		return s.log.ServiceTxError(err)
+	}
+	defer tx.Rollback()
+
+	if err = s.personalRepo.DeleteTX(ctx, tx, role, userId, id); err != nil {
+		if err == errors.PersonalTokenDoesNotExist {
+			return errors.AuthPersonalTokenNotFound
+		}
+		return s.log.ServiceDatabaseError(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return s.log.ServiceTxError(err)
+	}
+
+	return nil
+}
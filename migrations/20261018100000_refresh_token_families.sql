@@ -0,0 +1,33 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.admin_tokens ADD COLUMN family_id public.xid;
+UPDATE public.admin_tokens t SET family_id = f.family_id
+FROM (SELECT user_id, number, xid() AS family_id FROM public.admin_tokens GROUP BY user_id, number) f
+WHERE t.user_id = f.user_id AND t.number = f.number;
+ALTER TABLE public.admin_tokens ALTER COLUMN family_id SET NOT NULL;
+
+ALTER TABLE public.user_tokens ADD COLUMN family_id public.xid;
+UPDATE public.user_tokens t SET family_id = f.family_id
+FROM (SELECT user_id, number, xid() AS family_id FROM public.user_tokens GROUP BY user_id, number) f
+WHERE t.user_id = f.user_id AND t.number = f.number;
+ALTER TABLE public.user_tokens ALTER COLUMN family_id SET NOT NULL;
+
+CREATE TABLE public.rotated_refresh_tokens (
+  secret CHAR(64) PRIMARY KEY,
+  family_id public.xid NOT NULL,
+  role BIGINT NOT NULL,
+  user_id public.xid NOT NULL,
+  number BIGINT NOT NULL,
+  rotated_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+CREATE INDEX rotated_refresh_tokens_family_idx ON public.rotated_refresh_tokens (family_id);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.rotated_refresh_tokens;
+ALTER TABLE public.user_tokens DROP COLUMN family_id;
+ALTER TABLE public.admin_tokens DROP COLUMN family_id;
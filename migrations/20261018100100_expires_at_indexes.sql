@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE INDEX admin_tokens_expires_at_idx ON public.admin_tokens (expires_at);
+CREATE INDEX user_tokens_expires_at_idx ON public.user_tokens (expires_at);
+CREATE INDEX rotated_refresh_tokens_expires_at_idx ON public.rotated_refresh_tokens (expires_at);
+CREATE INDEX verification_tokens_expires_at_idx ON public.verification_tokens (expires_at);
+CREATE INDEX reset_tokens_expires_at_idx ON public.reset_tokens (expires_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP INDEX public.reset_tokens_expires_at_idx;
+DROP INDEX public.verification_tokens_expires_at_idx;
+DROP INDEX public.rotated_refresh_tokens_expires_at_idx;
+DROP INDEX public.user_tokens_expires_at_idx;
+DROP INDEX public.admin_tokens_expires_at_idx;
@@ -0,0 +1,61 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.tokens (
+  role BIGINT NOT NULL,
+  user_id public.xid NOT NULL,
+  number BIGINT NOT NULL,
+  purpose INTEGER NOT NULL,
+  secret CHAR(64) NOT NULL,
+  expires_at BIGINT NOT NULL,
+  family_id public.xid NOT NULL
+);
+ALTER TABLE public.tokens
+ADD CONSTRAINT tokens_pkey PRIMARY KEY (role, user_id, number, purpose);
+CREATE INDEX tokens_family_idx ON public.tokens (role, family_id);
+CREATE INDEX tokens_expires_at_idx ON public.tokens (expires_at);
+
+-- роли 0 и 1 соответствуют domain.RoleAdmin и domain.RoleUser
+INSERT INTO public.tokens (role, user_id, number, purpose, secret, expires_at, family_id)
+SELECT 0, user_id, number, purpose, secret, expires_at, family_id FROM public.admin_tokens;
+INSERT INTO public.tokens (role, user_id, number, purpose, secret, expires_at, family_id)
+SELECT 1, user_id, number, purpose, secret, expires_at, family_id FROM public.user_tokens;
+
+DROP TABLE public.admin_tokens;
+DROP TABLE public.user_tokens;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.admin_tokens (
+  user_id public.xid NOT NULL DEFAULT xid(),
+  number BIGINT NOT NULL,
+  purpose INTEGER NOT NULL,
+  secret CHAR(64) NOT NULL,
+  expires_at BIGINT NOT NULL,
+  family_id public.xid NOT NULL
+);
+ALTER TABLE public.admin_tokens
+ADD CONSTRAINT admin_tokens_pkey PRIMARY KEY (user_id, number, purpose);
+CREATE INDEX admin_tokens_expires_at_idx ON public.admin_tokens (expires_at);
+
+CREATE TABLE public.user_tokens (
+  user_id public.xid NOT NULL DEFAULT xid(),
+  number BIGINT NOT NULL,
+  purpose INTEGER NOT NULL,
+  secret CHAR(64) NOT NULL,
+  expires_at BIGINT NOT NULL,
+  family_id public.xid NOT NULL
+);
+ALTER TABLE public.user_tokens
+ADD CONSTRAINT user_tokens_pkey PRIMARY KEY (user_id, number, purpose);
+CREATE INDEX user_tokens_expires_at_idx ON public.user_tokens (expires_at);
+
+-- токены других ролей в старой схеме хранить негде
+INSERT INTO public.admin_tokens (user_id, number, purpose, secret, expires_at, family_id)
+SELECT user_id, number, purpose, secret, expires_at, family_id FROM public.tokens WHERE role = 0;
+INSERT INTO public.user_tokens (user_id, number, purpose, secret, expires_at, family_id)
+SELECT user_id, number, purpose, secret, expires_at, family_id FROM public.tokens WHERE role = 1;
+
+DROP TABLE public.tokens;
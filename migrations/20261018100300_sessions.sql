@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.sessions (
+  role BIGINT NOT NULL,
+  user_id public.xid NOT NULL,
+  number BIGINT NOT NULL,
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL,
+  last_used_at BIGINT NOT NULL
+);
+ALTER TABLE public.sessions
+ADD CONSTRAINT sessions_pkey PRIMARY KEY (role, user_id, number);
+
+-- для уже открытых сессий метаданных нет, время открытия неизвестно - ставим момент миграции
+INSERT INTO public.sessions (role, user_id, number, created_at, last_used_at)
+SELECT DISTINCT role, user_id, number,
+  (extract(epoch FROM now()) * 1000)::BIGINT,
+  (extract(epoch FROM now()) * 1000)::BIGINT
+FROM public.tokens;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.sessions;
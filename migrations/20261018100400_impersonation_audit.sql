@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.impersonation_audit (
+  id public.xid PRIMARY KEY DEFAULT xid(),
+  admin_id public.xid NOT NULL,
+  target_user_id public.xid NOT NULL,
+  target_role BIGINT NOT NULL,
+  number BIGINT NOT NULL,
+  reason TEXT NOT NULL,
+  ip TEXT NOT NULL DEFAULT '',
+  user_agent TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+CREATE INDEX impersonation_audit_admin_idx ON public.impersonation_audit (admin_id, created_at);
+CREATE INDEX impersonation_audit_target_idx ON public.impersonation_audit (target_user_id, created_at);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.impersonation_audit;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- скоупы через пробел, пустая строка - все скоупы роли
+ALTER TABLE public.sessions ADD COLUMN scope TEXT NOT NULL DEFAULT '';
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.sessions DROP COLUMN scope;
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.service_clients (
+  id public.xid PRIMARY KEY,
+  name TEXT NOT NULL,
+  secret_hash CHAR(64) NOT NULL,
+  scope TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL
+);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.service_clients;
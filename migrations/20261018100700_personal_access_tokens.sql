@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+CREATE TABLE public.personal_access_tokens (
+  id public.xid PRIMARY KEY,
+  role INT NOT NULL,
+  user_id public.xid NOT NULL,
+  name TEXT NOT NULL,
+  token_hash CHAR(64) NOT NULL UNIQUE,
+  scope TEXT NOT NULL DEFAULT '',
+  created_at BIGINT NOT NULL,
+  -- 0 - бессрочный токен
+  expires_at BIGINT NOT NULL DEFAULT 0,
+  last_used_at BIGINT NOT NULL DEFAULT 0
+);
+CREATE INDEX personal_access_tokens_user_idx ON public.personal_access_tokens (role, user_id);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.personal_access_tokens;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- отпечаток DPoP ключа, к которому привязаны токены сессии. Пусто - обычные bearer токены
+ALTER TABLE public.sessions ADD COLUMN jkt TEXT NOT NULL DEFAULT '';
+-- jti уже принятых DPoP proof, хранятся пока proof может пройти проверку по iat
+CREATE TABLE public.dpop_proofs (
+  jkt TEXT NOT NULL,
+  jti TEXT NOT NULL,
+  expires_at BIGINT NOT NULL,
+  PRIMARY KEY (jkt, jti)
+);
+CREATE INDEX dpop_proofs_expires_at_idx ON public.dpop_proofs (expires_at);
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.dpop_proofs;
+ALTER TABLE public.sessions DROP COLUMN jkt;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- время интерактивного входа в миллисекундах и способы входа через пробел (RFC 8176)
+ALTER TABLE public.sessions ADD COLUMN auth_time BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE public.sessions ADD COLUMN amr TEXT NOT NULL DEFAULT '';
+UPDATE public.sessions SET auth_time = created_at;
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.sessions DROP COLUMN amr;
+ALTER TABLE public.sessions DROP COLUMN auth_time;
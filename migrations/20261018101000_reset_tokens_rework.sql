@@ -0,0 +1,32 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- id генерирует сервис (xid), в token лежит sha256 в hex. Токены короткоживущие, поэтому таблица пересоздается.
+-- У пользователя может быть несколько запросов на сброс, после смены пароля удаляются все
+DROP TABLE public.reset_tokens;
+CREATE TABLE public.reset_tokens (
+  id public.xid PRIMARY KEY,
+  user_id public.xid NOT NULL,
+  token VARCHAR(64) NOT NULL,
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+CREATE INDEX reset_tokens_user_id_idx ON public.reset_tokens (user_id);
+CREATE INDEX reset_tokens_expires_at_idx ON public.reset_tokens (expires_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.reset_tokens;
+CREATE TABLE public.reset_tokens (
+  id BIGINT PRIMARY KEY,
+  user_id public.xid NOT NULL UNIQUE,
+  token VARCHAR(16) NOT NULL,
+  created_at BIGINT NOT NULL,
+  expires_at BIGINT NOT NULL
+);
+ALTER TABLE public.reset_tokens
+ADD CONSTRAINT unique_reset_token_per_user UNIQUE (user_id, token);
+CREATE INDEX reset_tokens_expires_at_idx ON public.reset_tokens (expires_at);
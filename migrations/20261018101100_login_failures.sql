@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- счетчики неудачных входов: scope account - по логину, ip - по адресу клиента. Время в миллисекундах.
+-- После expires_at счетчик начинается заново, expires_at не раньше конца блокировки
+CREATE TABLE public.login_failures (
+  scope VARCHAR(16) NOT NULL,
+  key VARCHAR(255) NOT NULL,
+  failures INT NOT NULL,
+  locked_until BIGINT NOT NULL DEFAULT 0,
+  expires_at BIGINT NOT NULL,
+  PRIMARY KEY (scope, key)
+);
+CREATE INDEX login_failures_expires_at_idx ON public.login_failures (expires_at);
+
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+DROP TABLE public.login_failures;
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+SELECT 'up SQL query';
+-- +goose StatementEnd
+-- кто действует от имени пользователя в сессии имперсонации. Пусто для обычных сессий
+ALTER TABLE public.sessions ADD COLUMN actor_id TEXT NOT NULL DEFAULT '';
+-- +goose Down
+-- +goose StatementBegin
+SELECT 'down SQL query';
+-- +goose StatementEnd
+ALTER TABLE public.sessions DROP COLUMN actor_id;